@@ -4,10 +4,14 @@ go 1.19
 
 require (
 	github.com/BurntSushi/toml v1.2.1
+	github.com/fsnotify/fsnotify v1.6.0
 	github.com/godror/godror v0.34.0
+	github.com/hashicorp/go-version v1.6.0
 	github.com/influxdata/telegraf v1.24.2
 	github.com/shirou/gopsutil/v3 v3.22.9
 	github.com/sirupsen/logrus v1.9.0
+	golang.org/x/exp v0.0.0-20230113152452-c42ee1cf562e
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -22,7 +26,6 @@ require (
 	github.com/gogo/protobuf v1.3.2 // indirect
 	github.com/golang/protobuf v1.5.2 // indirect
 	github.com/golang/snappy v0.0.4 // indirect
-	github.com/hashicorp/go-version v1.6.0 // indirect
 	github.com/lufia/plan9stats v0.0.0-20220913051719-115f729f3c8c // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.16 // indirect
@@ -37,7 +40,6 @@ require (
 	github.com/tklauser/numcpus v0.5.0 // indirect
 	github.com/wavefronthq/wavefront-sdk-go v0.10.4 // indirect
 	github.com/yusufpapurcu/wmi v1.2.2 // indirect
-	golang.org/x/exp v0.0.0-20230113152452-c42ee1cf562e // indirect
 	golang.org/x/sys v0.1.0 // indirect
 	google.golang.org/protobuf v1.28.1 // indirect
 )
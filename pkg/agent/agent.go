@@ -1,6 +1,8 @@
 package agent
 
 import (
+	"fmt"
+	"reflect"
 	"sync"
 	"time"
 
@@ -48,13 +50,21 @@ var (
 	// MainConfig contains the global configuration
 	MainConfig config.Config
 
-	log *logrus.Logger
+	log        *logrus.Logger
+	configFile string
 	// reloadMutex guards the reloadProcess flag
 	reloadMutex   sync.Mutex
 	reloadProcess bool
-	// mutex guards the runtime devices map access
-	mutex    sync.RWMutex
-	gatherWg sync.WaitGroup
+	// mutex guards the runtime group processors map access
+	mutex           sync.RWMutex
+	groupProcessors = make(map[string]*runningGroup)
+	gatherWg        sync.WaitGroup
+	// chStop is closed on End to finish the discovery process, chEnd is
+	// closed once all pending metrics are flushed to finish Start
+	chStop  = make(chan bool)
+	chEnd   = make(chan bool)
+	endOnce sync.Once
+	ending  bool // no more groups are started (guarded by mutex)
 
 	// processWg sync.WaitGroup
 )
@@ -64,40 +74,180 @@ func SetLogger(l *logrus.Logger) {
 	log = l
 }
 
-// End stops all devices polling.
+// SetConfigFile sets the config file to be read on reload.
+func SetConfigFile(f string) {
+	configFile = f
+}
+
+// runningGroup is a started group processor and its stop channel
+type runningGroup struct {
+	processor *MGroupProcessor
+	done      chan bool
+}
+
+// startGroup begins the collection for the group (mutex should be locked)
+func startGroup(group *config.OracleMetricGroupConfig) {
+	log.Infof("[COLLECTOR] Begin Collecting data from Group [%s]", group.Name)
+	rg := &runningGroup{
+		processor: InitGroupProcessor(group, oracle.OraList),
+		done:      make(chan bool),
+	}
+	rg.processor.StartCollection(rg.done, &gatherWg)
+	groupProcessors[group.Name] = rg
+}
+
+// End stops all devices polling and returns once all pending metrics are
+// flushed (or spooled), Start returns after it.
 func End() (time.Duration, error) {
 	start := time.Now()
-	// nothing to do
+	endOnce.Do(func() {
+		close(chStop)
+		mutex.Lock()
+		ending = true
+		for name, rg := range groupProcessors {
+			close(rg.done)
+			delete(groupProcessors, name)
+		}
+		mutex.Unlock()
+		// running queries should send their metrics before the flush
+		gatherWg.Wait()
+		output.End()
+		close(chEnd)
+	})
+	<-chEnd
 	return time.Since(start), nil
 }
 
-func Start() {
-	done := make(chan bool)
+// Start begins the collection, it returns on End or on initialization errors
+// (even if there are no groups running after a reload)
+func Start() error {
+	// init Output Sync process (before any other process sending metrics)
+	err := output.Init(MainConfig.Output)
+	if err != nil {
+		log.Errorf("[OUTPUT] %s", err)
+		return err
+	}
 	// init SelfMonitoring
 	selfmon.Init(MainConfig.Selfmon)
 
-	// init Output Sync process
-	output.Init(MainConfig.Output)
 	// init discovery process
-	oracle.InitDiscovery(MainConfig.Discovery, done)
+	oracle.InitDiscovery(MainConfig.Discovery, chStop)
 	// init SystemMonitor Process
 
 	cfg := MainConfig.OraMon
 
-	chains := make([]chan bool, len(cfg.MetricGroup))
-	for i, group := range cfg.MetricGroup {
-		chains[i] = make(chan bool)
-		log.Infof("[COLLECTOR] Begin [%d] Collecting data from Group [%s]", i, group.Name)
-		processor := InitGroupProcessor(group, oracle.OraList)
-		processor.StartCollection(chains[i], &gatherWg)
+	mutex.Lock()
+	for _, group := range cfg.MetricGroup {
+		if ending {
+			break
+		}
+		startGroup(group)
+	}
+	mutex.Unlock()
+	if len(MainConfig.General.APIListen) > 0 {
+		startAPI(MainConfig.General.APIListen)
 	}
-	// init OracleMonitor Process
-	gatherWg.Wait()
+	<-chEnd
+	return nil
 }
 
-// ReloadConf stops the polling, reloads all configuration and restart the polling.
+// reloadGroups stops the removed or changed group processors and starts the new ones
+func reloadGroups(cfg *config.OracleMonitorConfig) (added int, removed int, changed int, unchanged int) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if ending {
+		log.Warnf("[RELOAD] Collector is ending, groups will not be reloaded")
+		return
+	}
+	newGroups := make(map[string]*config.OracleMetricGroupConfig)
+	for _, g := range cfg.MetricGroup {
+		newGroups[g.Name] = g
+	}
+	running := make(map[string]bool)
+	stop := []*runningGroup{}
+	for name, rg := range groupProcessors {
+		running[name] = true
+		g, ok := newGroups[name]
+		switch {
+		case !ok:
+			log.Infof("[RELOAD] Group [%s] removed", name)
+			removed++
+		case !reflect.DeepEqual(g, rg.processor.cfg):
+			log.Infof("[RELOAD] Group [%s] changed", name)
+			changed++
+		default:
+			unchanged++
+			continue
+		}
+		stop = append(stop, rg)
+		delete(groupProcessors, name)
+	}
+	// new processors are started before stopping the old ones, the collector
+	// will not end while waiting on gatherWg
+	for _, g := range cfg.MetricGroup {
+		if _, ok := groupProcessors[g.Name]; ok {
+			continue
+		}
+		if !running[g.Name] {
+			log.Infof("[RELOAD] Group [%s] added", g.Name)
+			added++
+		}
+		startGroup(g)
+	}
+	for _, rg := range stop {
+		close(rg.done)
+		for _, q := range rg.processor.cfg.OracleMetrics {
+			output.UnregisterMetricConfig(q)
+		}
+	}
+	return added, removed, changed, unchanged
+}
+
+// ReloadConf reads and validates the config file again and applies the
+// changes in metric groups and discovery settings without restarting.
+// If the new config is not valid the running one is kept.
 func ReloadConf() (time.Duration, error) {
 	start := time.Now()
-	// nothing to do yet
-	return time.Since(start), nil
+	reloadMutex.Lock()
+	if reloadProcess {
+		reloadMutex.Unlock()
+		return time.Since(start), fmt.Errorf("Reload process already running")
+	}
+	reloadProcess = true
+	reloadMutex.Unlock()
+	defer func() {
+		reloadMutex.Lock()
+		reloadProcess = false
+		reloadMutex.Unlock()
+	}()
+
+	log.Infof("[RELOAD] Reloading config file %s", configFile)
+	cfg, err := config.LoadConfigFile(configFile)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		err = fmt.Errorf("Error on config file %s: %s (keeping running config)", configFile, err)
+		log.Errorf("[RELOAD] %s", err)
+		selfmon.SendReloadStats(false, err, 0, 0, 0, 0, time.Since(start))
+		return time.Since(start), err
+	}
+	// these sections are only read on start
+	if !reflect.DeepEqual(cfg.General, MainConfig.General) ||
+		!reflect.DeepEqual(cfg.Output, MainConfig.Output) ||
+		!reflect.DeepEqual(cfg.Selfmon, MainConfig.Selfmon) {
+		log.Warnf("[RELOAD] Changes on [general], [output] or [self-monitor] sections need a restart to be applied")
+	}
+	// secrets of the previous config are not needed anymore
+	config.SetSecrets("config", cfg.Secrets())
+	added, removed, changed, unchanged := reloadGroups(cfg.OraMon)
+	oracle.UpdateDiscoveryConfig(cfg.Discovery)
+	mutex.Lock()
+	MainConfig.Discovery = cfg.Discovery
+	MainConfig.OraMon = cfg.OraMon
+	mutex.Unlock()
+	d := time.Since(start)
+	log.Infof("[RELOAD] Config reloaded: groups added [%d] removed [%d] changed [%d] unchanged [%d] (Duration: %s)", added, removed, changed, unchanged, d)
+	selfmon.SendReloadStats(true, nil, added, removed, changed, unchanged, d)
+	return d, nil
 }
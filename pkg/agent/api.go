@@ -0,0 +1,42 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ReloadResult is the response of the reload API call
+type ReloadResult struct {
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// reloadHandler reloads the config file as on SIGHUP (only POST allowed)
+func reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST method allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	log.Infof("[API] Reload requested from %s", r.RemoteAddr)
+	d, err := ReloadConf()
+	res := ReloadResult{Duration: d.String()}
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		res.Error = err.Error()
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// startAPI begins the HTTP API server on the listen address
+func startAPI(listen string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/reload", reloadHandler)
+	go func() {
+		log.Infof("[API] Listening on %s", listen)
+		if err := http.ListenAndServe(listen, mux); err != nil {
+			log.Errorf("[API] Error on listen %s: %s", listen, err)
+		}
+	}()
+}
@@ -112,7 +112,7 @@ func (dt *DataTable) Transpose(columnName string) (*DataTable, error) {
 	}
 	// check if Idx not found
 	if idx < 0 {
-		err := fmt.Errorf("Error on Transpose, value field  found on table with headers [%+v]", tfield, dt.Header)
+		err := fmt.Errorf("Error on Transpose, value field not found on table with headers [%+v]", dt.Header)
 		return nil, err
 	}
 
@@ -1,6 +1,8 @@
 package agent
 
 import (
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,6 +18,8 @@ type MGroupProcessor struct {
 	OracleInstances []*oracle.OracleInstance
 	cfg             *config.OracleMetricGroupConfig
 	InstNames       []string
+	// group ticks on the greatest common divisor of all metric periods
+	tickPeriod time.Duration
 }
 
 func InitGroupProcessor(cfg *config.OracleMetricGroupConfig, oralist *oracle.InstanceList) *MGroupProcessor {
@@ -23,6 +27,10 @@ func InitGroupProcessor(cfg *config.OracleMetricGroupConfig, oralist *oracle.Ins
 		InstanceList: oralist,
 		cfg:          cfg,
 	}
+	ret.tickPeriod = cfg.TickPeriod()
+	for _, q := range cfg.OracleMetrics {
+		output.RegisterMetricConfig(q)
+	}
 	return &ret
 }
 
@@ -40,14 +48,42 @@ func (mgp *MGroupProcessor) UpdateInstances() int {
 			mgp.InstNames = append(mgp.InstNames, i.GetInstanceName())
 		}
 	}
-	mgp.OracleInstances = filtered
+	mgp.OracleInstances = nil
+	for _, i := range filtered {
+		if mgp.isValidType(i) {
+			mgp.OracleInstances = append(mgp.OracleInstances, i)
+		}
+	}
 	mgp.InstNames = oracle.GetSidNames(mgp.OracleInstances)
 	ntotal := len(instances)
-	nfilter := len(filtered)
+	nfilter := len(mgp.OracleInstances)
 	log.Debugf("[COLLECTOR] On update Number instances total [%d] Filtered [%d]", ntotal, nfilter)
 	return nfilter
 }
 
+// statusLevel orders the instance status: STARTED (NOMOUNT) < MOUNTED < OPEN
+func statusLevel(status string) int {
+	switch {
+	case status == "STARTED":
+		return 1
+	case status == "MOUNTED":
+		return 2
+	case strings.HasPrefix(status, "OPEN"):
+		return 3
+	}
+	return 0
+}
+
+// isValidType checks the instance type with the group instance_types
+func (mgp *MGroupProcessor) isValidType(i *oracle.OracleInstance) bool {
+	for _, t := range mgp.cfg.InstanceTypes {
+		if t == i.GetInstanceType() {
+			return true
+		}
+	}
+	return false
+}
+
 // lt = lessThan
 // goet = greater or equal than
 func checkVersions(i *oracle.OracleInstance, goet, lt string) (string, bool) {
@@ -62,41 +98,134 @@ func checkVersions(i *oracle.OracleInstance, goet, lt string) (string, bool) {
 	return "", true
 }
 
-func (mgp *MGroupProcessor) ProcesQuery() {
+// dueMetrics returns the metrics to be queried on the tick number
+func (mgp *MGroupProcessor) dueMetrics(tick int64) []*config.OracleMetricConfig {
+	var due []*config.OracleMetricConfig
+	for _, q := range mgp.cfg.OracleMetrics {
+		if tick%int64(q.QueryPeriod/mgp.tickPeriod) == 0 {
+			due = append(due, q)
+		}
+	}
+	return due
+}
+
+// queryPDBs returns the open PDBs matching the pdb_filter (seed is never queried)
+func (mgp *MGroupProcessor) queryPDBs(i *oracle.OracleInstance) []oracle.PdbInfo {
+	var pdbs []oracle.PdbInfo
+	for _, pdb := range i.GetPDBs() {
+		if pdb.Name == "PDB$SEED" || !strings.HasPrefix(pdb.OpenMode, "READ") {
+			continue
+		}
+		if mgp.cfg.PdbR != nil && !mgp.cfg.PdbR.MatchString(pdb.Name) {
+			continue
+		}
+		pdbs = append(pdbs, pdb)
+	}
+	return pdbs
+}
+
+// runQuery sends the metrics of the query on the instance (or on the PDB if not nil)
+func (mgp *MGroupProcessor) runQuery(i *oracle.OracleInstance, q *config.OracleMetricConfig, pdb *oracle.PdbInfo, extraLabels map[string]string) {
+	table := data.NewDatatableWithConfig(q)
+	var n int
+	var d time.Duration
+	var err error
+	si := oracle.SessionInfo{Action: q.ID, ClientInfo: mgp.cfg.Name}
+	// group settings first, restored in reverse order
+	si.Init = append(append(si.Init, mgp.cfg.SessionInit...), q.SessionInit...)
+	si.Reset = append(append(si.Reset, q.SessionReset...), mgp.cfg.SessionReset...)
+	// group params overridden by the metric ones
+	params := make(map[string]string, len(mgp.cfg.Params)+len(q.Params))
+	for k, v := range mgp.cfg.Params {
+		params[k] = v
+	}
+	for k, v := range q.Params {
+		params[k] = v
+	}
+	query, args, err := i.PrepareRequest(q, params)
+	if err != nil {
+		mgp.Errorf(i, "Oracle Metric Query: [%s] %s", q.Context, err)
+		selfmon.SendQueryStat(extraLabels, mgp.cfg, q, 0, 0, err)
+		return
+	}
+	if pdb != nil {
+		mgp.Debugf(i, "Begin Metric Query: [%s] on PDB [%s]", q.Context, pdb.Name)
+		n, d, err = i.QueryPDB(q.QueryTimeout, si, pdb.Name, query, args, table)
+	} else {
+		mgp.Debugf(i, "Begin Metric Query: [%s]", q.Context)
+		n, d, err = i.Query(q.QueryTimeout, si, query, args, table)
+	}
+	if err != nil {
+		mgp.Errorf(i, "Error on query: %s (Duration: %s)", err, d)
+		selfmon.SendQueryStat(extraLabels, mgp.cfg, q, 0, d, err)
+		return
+	}
+	mgp.Infof(i, "Oracle Metric Query: [%s] returned [%d] rows (Transposed by: %s)(Duration: %s)", q.Context, n, q.FieldToAppend, d)
+	// Data transformation.
+	metrics, err := table.GetMetrics(extraLabels)
+	if err != nil {
+		mgp.Warnf(i, "Oracle Metric Query: [%s] Error on  metric transformation: %s", q.Context, err)
+		return
+	}
+	output.SendMetrics(metrics)
+	selfmon.SendQueryStat(extraLabels, mgp.cfg, q, n, d, nil)
+}
+
+func (mgp *MGroupProcessor) ProcesQuery(tick int64) {
+	metrics := mgp.dueMetrics(tick)
+	if len(metrics) == 0 {
+		return
+	}
 	n := mgp.UpdateInstances()
 	mgp.BroadCastInfof("Init Query Process on [%d] Instances [%+v] ", n, mgp.InstNames)
 
 	log.Infof("[COLLECTOR] Processor [%s] new Iteration on [%d] Instances [%+v]", mgp.cfg.Name, n, mgp.InstNames)
 	for _, i := range mgp.OracleInstances {
+		// instances DOWN are reconnecting
+		if !i.IsQueryable() {
+			state, since := i.GetState()
+			mgp.Infof(i, "SKIP querying instance %s : state %s since %s", i.GetInstanceName(), state, since.Format(time.RFC3339))
+			continue
+		}
+		// MOUNTED/STARTED instances can only run groups requiring these status
+		if status := i.GetStatus(); statusLevel(status) < statusLevel(mgp.cfg.RequiredStatus) {
+			mgp.Infof(i, "SKIP querying instance %s : status %s (required %s)", i.GetInstanceName(), status, mgp.cfg.RequiredStatus)
+			continue
+		}
 		// check if this instance should be queried
-		if mgp.cfg.QueryLevel == "db" && !i.GetIsValidForDBQuery() {
-			mgp.Infof(i, "QUERY IN DB MODE: SKIP querying instance %s : not smalest Instance in DB (Current %d)", i.InstInfo.InstName, i.InstInfo.InstNumber)
+		if mgp.cfg.QueryLevel != "instance" && !i.GetIsValidForDBQuery() {
+			mgp.Infof(i, "QUERY IN %s MODE: SKIP querying instance %s : not smalest Instance in DB (Current %d)", strings.ToUpper(mgp.cfg.QueryLevel), i.InstInfo.InstName, i.InstInfo.InstNumber)
 			continue
 		}
+		var pdbs []oracle.PdbInfo
+		if mgp.cfg.QueryLevel == "pdb" {
+			pdbs = mgp.queryPDBs(i)
+			if len(pdbs) == 0 {
+				mgp.Infof(i, "QUERY IN PDB MODE: SKIP querying instance %s : no open PDBs (or not matching pdb_filter)", i.InstInfo.InstName)
+				continue
+			}
+		}
 		extraLabels := i.GetExtraLabels()
-		for _, q := range mgp.cfg.OracleMetrics {
+		for _, q := range metrics {
 			// check version affinity
 			v, match := checkVersions(i, q.OraVerGreaterOrEqualThan, q.OraVerLessThan)
 			if !match {
 				mgp.Infof(i, "Metric Query: [%s] | version filter [ %s, %s ): NOT MATCH IN Instance [%s]version[%s]", q.Context, q.OraVerGreaterOrEqualThan, q.OraVerLessThan, i.GetInstanceName(), v)
 				continue
 			}
-			mgp.Debugf(i, "Begin Metric Query: [%s]", q.Context)
-			table := data.NewDatatableWithConfig(q)
-			n, d, err := i.Query(mgp.cfg.QueryTimeout, q.Request, table)
-			if err != nil {
-				mgp.Errorf(i, "Error on query: %s (Duration: %s)", err, d)
+			if mgp.cfg.QueryLevel != "pdb" {
+				mgp.runQuery(i, q, nil, extraLabels)
 				continue
 			}
-			mgp.Infof(i, "Oracle Metric Query: [%s] returned [%d] rows (Transposed by: %s)(Duration: %s)", q.Context, n, q.FieldToAppend, d)
-			// Data transformation.
-			metrics, err := table.GetMetrics(extraLabels)
-			if err != nil {
-				mgp.Warnf(i, "Oracle Metric Query: [%s] Error on  metric transformation: %s", q.Context, err)
-				continue
+			for k := range pdbs {
+				pdbLabels := make(map[string]string, len(extraLabels)+2)
+				for lk, lv := range extraLabels {
+					pdbLabels[lk] = lv
+				}
+				pdbLabels["pdb_name"] = pdbs[k].Name
+				pdbLabels["con_id"] = strconv.Itoa(pdbs[k].ConID)
+				mgp.runQuery(i, q, &pdbs[k], pdbLabels)
 			}
-			output.SendMetrics(metrics)
-			selfmon.SendQueryStat(extraLabels, mgp.cfg, q, n, d)
 		}
 	}
 }
@@ -106,19 +235,21 @@ func (mgp *MGroupProcessor) StartCollection(done chan bool, s *sync.WaitGroup) {
 	go func() {
 		defer s.Done()
 
-		qTicker := time.NewTicker(mgp.cfg.QueryPeriod)
+		qTicker := time.NewTicker(mgp.tickPeriod)
 		defer qTicker.Stop()
 
 		first := make(chan bool, 1)
 		first <- true
+		var tick int64
 
 		for {
 			select {
 			case <-first:
-				log.Infof("[COLLECTOR] Start Query Processor for Group:  %s ( Period: %s )", mgp.cfg.Name, mgp.cfg.QueryPeriod.String())
-				mgp.ProcesQuery()
+				log.Infof("[COLLECTOR] Start Query Processor for Group:  %s ( Period: %s Tick: %s )", mgp.cfg.Name, mgp.cfg.QueryPeriod.String(), mgp.tickPeriod.String())
+				mgp.ProcesQuery(tick)
 			case <-qTicker.C:
-				mgp.ProcesQuery()
+				tick++
+				mgp.ProcesQuery(tick)
 			case <-done:
 				return
 			}
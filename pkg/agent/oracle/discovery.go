@@ -8,37 +8,149 @@ import (
 	"github.com/toni-moreno/oracle_collector/pkg/config"
 )
 
-func ScanSystemForInstances(procPattern string, loglevel string) ([]*OracleInstance, error) {
+// discoverTargets returns the instances found by all enabled providers,
+// targets with an identity already found and remote targets named as a
+// local instance SID are skipped
+func discoverTargets(cfg *config.DiscoveryConfig) []*OracleInstance {
 	DetectedInstances := []*OracleInstance{}
+	found := make(map[string]string)
+	localSids := make(map[string]bool)
+	for _, p := range GetProviders() {
+		if !p.Enabled(cfg) {
+			continue
+		}
+		start := time.Now()
+		targets, err := p.Discover(cfg)
+		if err != nil {
+			log.Errorf("[DISCOVERY] Provider %s: %s", p.Name(), err)
+		}
+		duplicated := 0
+		for _, t := range targets {
+			if prev, ok := found[t.ID]; ok {
+				log.Warnf("[DISCOVERY] Provider %s: target %s already discovered by provider %s, skipping", p.Name(), t.ID, prev)
+				duplicated++
+				continue
+			}
+			if t.Target != nil && localSids[t.ID] {
+				log.Errorf("[DISCOVERY] Provider %s: target name %s is the SID of a local instance, skipping (target names should not collide with local SIDs)", p.Name(), t.ID)
+				duplicated++
+				continue
+			}
+			if t.Target == nil {
+				localSids[t.SID] = true
+			}
+			found[t.ID] = p.Name()
+			DetectedInstances = append(DetectedInstances, t.newInstance())
+		}
+		log.Debugf("[DISCOVERY] Provider %s: Found [%d] targets (%d duplicated) in %s", p.Name(), len(targets), duplicated, time.Since(start))
+		selfmon.SendDiscoveryProviderStats(p.Name(), len(targets), duplicated, err, time.Since(start))
+	}
+	return DetectedInstances
+}
 
-	pf := ProcessFinder{}
+func sendStateStats(inst *OracleInstance) {
+	state, since := inst.GetState()
+	selfmon.SendInstanceStateStats(inst.GetInstanceName(), string(state), time.Since(since), inst.GetReconnects())
+}
 
-	pmonfound, err := pf.FullPattern(procPattern)
-	if err != nil {
-		log.Errorf("[DISCOVERY]: Error on scanning system processes with pattern [%s] : Err %s", procPattern, err)
+// isSkippedError checks if the error matches oracle_discovery_skip_errors_regex
+func isSkippedError(cfg *config.DiscoveryConfig, err error) bool {
+	for _, skipr := range cfg.SkipErrR {
+		if skipr.MatchString(err.Error()) {
+			return true
+		}
 	}
+	return false
+}
 
-	for sid, proc := range pmonfound {
-
-		orainst := &OracleInstance{
-			DiscoveredSid: sid,
-			PMONpid:       proc.Pid,
+// initInstance connects to the instance and adds it to the instance list,
+// on errors the instance is added to the pending ones to be retried
+func initInstance(cfg *config.DiscoveryConfig, inst *OracleInstance) error {
+	err := inst.Init(cfg.OracleLogLevel, cfg.OracleClusterwareEnabled, cfg.OracleStatusExtendedInfo)
+	if err != nil {
+		p := setPending(inst, err)
+		if isSkippedError(cfg, err) {
+			log.Errorf("[DISCOVERY] Error On Initialize Instance [SKIPPED by oracle_discovery_skip_errors_regex ] %s: %s (attempt %d, next retry at %s)", inst.DiscoveredSid, err, p.Attempts, p.NextRetry.Format(time.RFC3339))
+		} else {
+			log.Errorf("[DISCOVERY] Error On Initialize Instance %s: %s (attempt %d, next retry at %s)", inst.DiscoveredSid, err, p.Attempts, p.NextRetry.Format(time.RFC3339))
 		}
+		output.SendMetrics(p.Metrics())
+		return err
+	}
+	if p, ok := pending[inst.DiscoveredSid]; ok {
+		log.Infof("[DISCOVERY] Pending Instance %s initialized after %d attempts (failing since %s)", inst.DiscoveredSid, p.Attempts+1, p.FirstFailure.Format(time.RFC3339))
+		delete(pending, inst.DiscoveredSid)
+	}
+	OraList.Add(inst)
+	output.SendMetrics(inst.GetMetrics(true))
+	sendStateStats(inst)
+	return nil
+}
 
-		DetectedInstances = append(DetectedInstances, orainst)
+// removeInstance ends the instance monitoring and sends its last status,
+// its series are expired at the end: nothing should be sent after it
+func removeInstance(inst *OracleInstance) error {
+	err := inst.End()
+	if err != nil {
+		return err
 	}
+	OraList.Delete(inst)
+	output.SendMetrics(inst.GetMetrics(false))
+	selfmon.SendSQLDriverStat(inst.GetInstanceName(), inst.GetDriverStats())
+	// stale series for this instance should not be exposed anymore
+	output.ExpireSeries(map[string]string{"instance": inst.GetInstanceName()})
+	return nil
+}
 
-	return DetectedInstances, nil
+// retryPending initializes the pending instances with its retry time reached
+func retryPending(cfg *config.DiscoveryConfig) {
+	now := time.Now()
+	for _, p := range pending {
+		if !p.Due(now) {
+			continue
+		}
+		p.Inst.cfg = cfg
+		log.Infof("[DISCOVERY] Retrying Pending Instance %s (failing since %s)", p.Inst.DiscoveredSid, p.FirstFailure.Format(time.RFC3339))
+		initInstance(cfg, p.Inst)
+	}
 }
 
 func discover(cfg *config.DiscoveryConfig) {
-	oinstances, err := ScanSystemForInstances(cfg.OracleDiscoverySidRegex, cfg.OracleLogLevel)
-	if err != nil {
-		log.Errorf("[DISCOVERY] Error on scan instances :%s", err)
-		return
+	oinstances := discoverTargets(cfg)
+	var err error
+	var oratab []*OratabEntry
+	var notRunning []*OratabEntry
+	if cfg.OracleOratabEnabled {
+		oratab, err = ReadOratab(cfg.OracleOratabFile)
+		if err != nil {
+			log.Errorf("[DISCOVERY] Error on reading oratab file %s: %s", cfg.OracleOratabFile, err)
+		}
+		local := []*OracleInstance{}
+		for _, inst := range oinstances {
+			if inst.Target != nil {
+				continue
+			}
+			// HOME from the process pattern first
+			if e := oratabEntryFor(oratab, inst.GetSid()); e != nil && len(inst.OracleHome) == 0 {
+				inst.OracleHome = e.OracleHome
+			}
+			local = append(local, inst)
+		}
+		notRunning = oratabNotRunning(oratab, local)
+		log.Debugf("[DISCOVERY] Oratab: Expected [%d] databases, not running [%d]", len(oratab), len(notRunning))
 	}
 	log.Debugf("[DISCOVERY] System: ===========================================")
 	log.Debugf("[DISCOVERY] System: Found [%d] Oracle Intances [%+v]", len(oinstances), GetSidNames(oinstances))
+	// targets with new connection parameters should be connected again
+	for _, inst := range OraList.GetList() {
+		if targetChanged(inst, oinstances) {
+			log.Infof("[DISCOVERY] Target %s connection parameters changed, reconnecting", inst.DiscoveredSid)
+			// same removal as lost instances: last status and expired series
+			if err := removeInstance(inst); err != nil {
+				log.Errorf("[DISCOVERY] Error on release Instance monitor resources for [%s]: Err: %s", inst.DiscoveredSid, err)
+			}
+		}
+	}
 	existing := OraList.GetList()
 	log.Debugf("[DISCOVERY] System: Existing [%d] Oracle Intances [%+v]", len(existing), GetSidNames(existing))
 	new, old, same := OraList.GetNewAndOldInstances(oinstances)
@@ -50,73 +162,113 @@ func discover(cfg *config.DiscoveryConfig) {
 	errorConnectSids := []string{}
 	errorSkipped := 0
 	errorSkippedSids := []string{}
+	countError := func(sid string, err error) {
+		if isSkippedError(cfg, err) {
+			errorSkipped++
+			errorSkippedSids = append(errorSkippedSids, sid)
+		} else {
+			errorConnect++
+			errorConnectSids = append(errorConnectSids, sid)
+		}
+	}
+	now := time.Now()
 	for _, inst := range new {
+		p := pendingFor(inst)
+		if p != nil {
+			// already failed: retried on its own schedule
+			inst = p.Inst
+			if !p.Due(now) {
+				log.Debugf("[DISCOVERY] Pending Instance %s: next retry at %s (last error: %s)", inst.DiscoveredSid, p.NextRetry.Format(time.RFC3339), p.LastError)
+				countError(inst.DiscoveredSid, p.LastError)
+				output.SendMetrics(p.Metrics())
+				continue
+			}
+		}
 		inst.cfg = cfg
 		log.Infof("[DISCOVERY] New Instance found: %s", inst.DiscoveredSid)
-		err := inst.Init(cfg.OracleLogLevel, cfg.OracleClusterwareEnabled, cfg.OracleStatusExtendedInfo)
-		if err != nil {
-			skip := false
-			for _, skipr := range cfg.SkipErrR {
-				skip = skipr.MatchString(err.Error())
-				if skip {
-					break
-				}
-			}
-			if skip {
-				errorSkipped++
-				errorSkippedSids = append(errorSkippedSids, inst.DiscoveredSid)
-				log.Errorf("[DISCOVERY] Error On Initialize Instance [SKIPPED by oracle_discovery_skip_errors_regex ] %s: %s", inst.DiscoveredSid, err)
-			} else {
-				errorConnect++
-				errorConnectSids = append(errorConnectSids, inst.DiscoveredSid)
-				log.Errorf("[DISCOVERY] Error On Initialize Instance %s: %s", inst.DiscoveredSid, err)
-			}
-
+		if err := initInstance(cfg, inst); err != nil {
+			countError(inst.DiscoveredSid, err)
 			continue
 		}
-		OraList.Add(inst)
-		output.SendMetrics(inst.GetMetrics(true))
 	}
+	dropPending(oinstances)
 	log.Debugf("[DISCOVERY] Old Instances Found [%d]: %+v", len(old), GetSidNames(old))
 	for _, inst := range old {
 		log.Infof("[DISCOVERY] Instance %s is LOST", inst.DiscoveredSid)
-		err := inst.End()
+		err := removeInstance(inst)
 		if err != nil {
 			log.Errorf("[DISCOVERY] Error on release Instance monitor resources for [%s]: Err: %s", inst.DiscoveredSid, err)
 			break
 		}
-		OraList.Delete(inst)
-		output.SendMetrics(inst.GetMetrics(false))
-		selfmon.SendSQLDriverStat(inst.GetInstanceName(), inst.GetDriverStats())
 	}
 	log.Debugf("[DISCOVERY] Same Instances Found [%d]: %+v", len(same), GetSidNames(same))
 	// for all other instances should update status and send metrics.
 
 	for _, inst := range same {
+		for _, t := range oinstances {
+			if t.DiscoveredSid != inst.DiscoveredSid {
+				continue
+			}
+			if inst.Target != nil && t.Target != nil {
+				inst.SetTarget(t.Target)
+			} else if inst.Target == nil && len(t.OracleHome) > 0 {
+				inst.SetOracleHome(t.OracleHome)
+			}
+		}
 		err := inst.UpdateInfo()
 		if err != nil {
 			log.Errorf("[DISCOVERY] Error on Update Instance Info for [%s]: Err: %s", inst.DiscoveredSid, err)
 		}
-		output.SendMetrics(inst.GetMetrics(true))
+		// static targets have no process: report the connection status
+		ok := inst.Target == nil || err == nil
+		output.SendMetrics(inst.GetMetrics(ok))
 		selfmon.SendSQLDriverStat(inst.GetInstanceName(), inst.GetDriverStats())
+		sendStateStats(inst)
+	}
+
+	// expected databases from oratab (not just lost in this iteration)
+	for _, e := range notRunning {
+		lost := false
+		for _, inst := range old {
+			lost = lost || oratabEntryFor([]*OratabEntry{e}, inst.GetSid()) != nil
+		}
+		if lost {
+			continue
+		}
+		log.Warnf("[DISCOVERY] Oratab: database %s (%s) is not running", e.SID, e.OracleHome)
+		output.SendMetrics(notRunningMetrics(cfg, e))
 	}
 
 	selfmon.SendDiscoveryMetrics(
-		len(oinstances),  // discovered_all
-		len(new),         // discovered_new
-		len(same),        // discovered_current
-		len(old),         // undiscovered
-		errorConnect,     // connect_errors
-		errorSkipped,     // connect_errors_skipped
-		GetSidNames(new), // discovered_new_str
-		GetSidNames(old), // undiscovered_str
-		errorConnectSids, // err_con_sids
-		errorSkippedSids) // skipped_con_sids
+		len(oinstances),       // discovered_all
+		len(new),              // discovered_new
+		len(same),             // discovered_current
+		len(old),              // undiscovered
+		errorConnect,          // connect_errors
+		errorSkipped,          // connect_errors_skipped
+		GetSidNames(new),      // discovered_new_str
+		GetSidNames(old),      // undiscovered_str
+		errorConnectSids,      // err_con_sids
+		errorSkippedSids,      // skipped_con_sids
+		GetPendingInstances()) // pending_sids
 }
 
 func discoveryProcess(cfg *config.DiscoveryConfig, done chan bool) {
 	discoveryTicker := time.NewTicker(cfg.OracleDiscoveryInterval)
 	defer discoveryTicker.Stop()
+	// pending instances are retried with its own backoff
+	retryTicker := time.NewTicker(pendingMinBackoff)
+	defer retryTicker.Stop()
+
+	watcher, err := startFileWatcher(cfg.OracleDiscoveryFiles)
+	if err != nil {
+		log.Errorf("[DISCOVERY] Error on watching discovery files, changes will be read every %s: %s", cfg.OracleDiscoveryInterval, err)
+	}
+	defer func() {
+		if watcher != nil {
+			watcher.Close()
+		}
+	}()
 
 	first := make(chan bool, 1)
 	first <- true
@@ -129,12 +281,54 @@ func discoveryProcess(cfg *config.DiscoveryConfig, done chan bool) {
 		case t := <-discoveryTicker.C:
 			log.Infof("[DISCOVERY] Scanning Again oracle instances at %s", t)
 			discover(cfg)
+		case <-retryTicker.C:
+			retryPending(cfg)
+		case <-chFileChanged:
+			log.Info("[DISCOVERY] Discovery files changed, scanning again oracle instances")
+			discover(cfg)
+		case newcfg := <-chUpdateCfg:
+			log.Infof("[DISCOVERY] Updating discovery config (Interval: %s)", newcfg.OracleDiscoveryInterval)
+			cfg = newcfg
+			discoveryTicker.Reset(cfg.OracleDiscoveryInterval)
+			for _, inst := range OraList.GetList() {
+				inst.UpdateConfig(cfg)
+			}
+			if watcher != nil {
+				watcher.Close()
+			}
+			watcher, err = startFileWatcher(cfg.OracleDiscoveryFiles)
+			if err != nil {
+				log.Errorf("[DISCOVERY] Error on watching discovery files, changes will be read every %s: %s", cfg.OracleDiscoveryInterval, err)
+			}
 		case <-done:
 			return
 		}
 	}
 }
 
+// chUpdateCfg sends new configurations to the discovery process (only the
+// last one is kept if the process is busy)
+var chUpdateCfg = make(chan *config.DiscoveryConfig, 1)
+
 func InitDiscovery(cfg *config.DiscoveryConfig, done chan bool) {
 	go discoveryProcess(cfg, done)
 }
+
+// UpdateDiscoveryConfig applies the new discovery settings to the running
+// process and the labels of the already discovered instances. Connection
+// parameters will only be used on new connections. It does not block while
+// the discovery process is busy: a pending older config is replaced.
+func UpdateDiscoveryConfig(cfg *config.DiscoveryConfig) {
+	for {
+		select {
+		case chUpdateCfg <- cfg:
+			return
+		default:
+			select {
+			case old := <-chUpdateCfg:
+				log.Infof("[DISCOVERY] Discarding pending discovery config (Interval: %s)", old.OracleDiscoveryInterval)
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package oracle
+
+import (
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/toni-moreno/oracle_collector/pkg/agent/selfmon"
+	"github.com/toni-moreno/oracle_collector/pkg/config"
+)
+
+func init() {
+	l := logrus.New()
+	l.SetOutput(ioutil.Discard)
+	SetLogger(l)
+	selfmon.SetLogger(l)
+	selfmon.Init(&config.SelfMonConfig{})
+}
+
+// testProvider returns a fixed list of targets
+type testProvider struct {
+	name    string
+	targets []*DiscoveredTarget
+}
+
+func (tp *testProvider) Name() string {
+	return tp.name
+}
+
+func (tp *testProvider) Enabled(cfg *config.DiscoveryConfig) bool {
+	return true
+}
+
+func (tp *testProvider) Discover(cfg *config.DiscoveryConfig) ([]*DiscoveredTarget, error) {
+	return tp.targets, nil
+}
+
+// withProviders replaces the registered providers while running f
+func withProviders(p []DiscoveryProvider, f func()) {
+	providersMutex.Lock()
+	saved := providers
+	providers = p
+	providersMutex.Unlock()
+	defer func() {
+		providersMutex.Lock()
+		providers = saved
+		providersMutex.Unlock()
+	}()
+	f()
+}
+
+func TestDiscoverTargets(t *testing.T) {
+	local := &testProvider{name: "pmon", targets: []*DiscoveredTarget{
+		{ID: "ORCL@oracle", SID: "ORCL", OSUser: "oracle"},
+		{ID: "ORCL@grid", SID: "ORCL", OSUser: "grid"},
+	}}
+	static := &testProvider{name: "static", targets: []*DiscoveredTarget{
+		{ID: "ORCL", Target: &config.TargetConfig{Name: "ORCL", OracleConnectDSN: "db1/ORCL"}},
+		{ID: "PRO1", Target: &config.TargetConfig{Name: "PRO1", OracleConnectDSN: "db1/PRO1"}},
+	}}
+	file := &testProvider{name: "file", targets: []*DiscoveredTarget{
+		{ID: "PRO1", Target: &config.TargetConfig{Name: "PRO1", OracleConnectDSN: "db2/PRO1"}},
+		{ID: "PRO2", Target: &config.TargetConfig{Name: "PRO2", OracleConnectDSN: "db2/PRO2"}},
+	}}
+	var got []string
+	withProviders([]DiscoveryProvider{local, static, file}, func() {
+		for _, inst := range discoverTargets(&config.DiscoveryConfig{}) {
+			got = append(got, inst.DiscoveredSid)
+		}
+	})
+	sort.Strings(got)
+	want := []string{"ORCL@grid", "ORCL@oracle", "PRO1", "PRO2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,97 @@
+package oracle
+
+import (
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/toni-moreno/oracle_collector/pkg/agent/output"
+)
+
+// InstanceEvent is a change detected between two instance info updates
+type InstanceEvent struct {
+	Type     string // restart, role_change, open_mode_change, status_change, version_change
+	OldValue string
+	NewValue string
+}
+
+// instanceSnapshot has the instance info checked for changes
+type instanceSnapshot struct {
+	StartupTime  string
+	DatabaseRole string
+	OpenMode     string
+	Status       string
+	Version      string
+}
+
+// snapshot returns the current info, oi should be locked
+func (oi *OracleInstance) snapshot() instanceSnapshot {
+	return instanceSnapshot{
+		StartupTime:  oi.InstInfo.StartupTime,
+		DatabaseRole: oi.DBInfo.DatabaseRole,
+		OpenMode:     oi.DBInfo.OpenMode,
+		Status:       oi.InstInfo.Status,
+		Version:      oi.InstInfo.Version,
+	}
+}
+
+// detectChanges compares the previous info with the current one
+func detectChanges(prev instanceSnapshot, cur instanceSnapshot) []InstanceEvent {
+	// first update: nothing to compare with
+	if len(prev.StartupTime) == 0 {
+		return nil
+	}
+	events := []InstanceEvent{}
+	check := func(typ string, old string, new string) {
+		if old != new {
+			events = append(events, InstanceEvent{Type: typ, OldValue: old, NewValue: new})
+		}
+	}
+	check("restart", prev.StartupTime, cur.StartupTime)
+	check("role_change", prev.DatabaseRole, cur.DatabaseRole)
+	check("open_mode_change", prev.OpenMode, cur.OpenMode)
+	check("status_change", prev.Status, cur.Status)
+	check("version_change", prev.Version, cur.Version)
+	return events
+}
+
+// EventMetrics returns the oracle_events metrics for the instance events
+func (oi *OracleInstance) EventMetrics(events []InstanceEvent) []telegraf.Metric {
+	ret := []telegraf.Metric{}
+	now := time.Now()
+	for _, e := range events {
+		tags := make(map[string]string)
+		for k, v := range oi.GetExtraLabels() {
+			tags[k] = v
+		}
+		tags["event_type"] = e.Type
+		fields := make(map[string]interface{})
+		fields["old_value"] = e.OldValue
+		fields["new_value"] = e.NewValue
+		ret = append(ret, metric.New("oracle_events", tags, fields, now))
+	}
+	return ret
+}
+
+// resetOnRestart resets the state kept from the previous instance run: the
+// consecutive query timeouts and the exposed series (counters are sent as
+// read from the instance, the prometheus series are the only values kept)
+func (oi *OracleInstance) resetOnRestart() {
+	oi.stateMutex.Lock()
+	oi.timeouts = 0
+	oi.stateMutex.Unlock()
+	output.ExpireSeries(map[string]string{"instance": oi.GetInstanceName()})
+}
+
+// sendEvents logs and sends the instance events, on restarts the instance
+// state from the previous run is reset (counters are not valid anymore)
+func (oi *OracleInstance) sendEvents(events []InstanceEvent) {
+	for _, e := range events {
+		log.Warnf("[DISCOVERY] Instance %s event %s: [%s] => [%s]", oi.DiscoveredSid, e.Type, e.OldValue, e.NewValue)
+		oi.log.Warnf("Instance event %s: [%s] => [%s]", e.Type, e.OldValue, e.NewValue)
+		if e.Type == "restart" {
+			oi.resetOnRestart()
+		}
+	}
+	output.SendMetrics(oi.EventMetrics(events))
+}
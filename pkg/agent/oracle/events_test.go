@@ -0,0 +1,84 @@
+package oracle
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDetectChanges(t *testing.T) {
+	prev := instanceSnapshot{StartupTime: "2022-10-01 10:00:00", DatabaseRole: "PRIMARY", OpenMode: "READ WRITE", Status: "OPEN", Version: "19.0.0.0.0"}
+	tests := []struct {
+		name string
+		prev instanceSnapshot
+		cur  func(s instanceSnapshot) instanceSnapshot
+		want []InstanceEvent
+	}{
+		{
+			name: "first update",
+			prev: instanceSnapshot{},
+			cur:  func(s instanceSnapshot) instanceSnapshot { return s },
+			want: nil,
+		},
+		{
+			name: "no changes",
+			prev: prev,
+			cur:  func(s instanceSnapshot) instanceSnapshot { return s },
+			want: []InstanceEvent{},
+		},
+		{
+			name: "restart",
+			prev: prev,
+			cur: func(s instanceSnapshot) instanceSnapshot {
+				s.StartupTime = "2022-10-02 10:00:00"
+				return s
+			},
+			want: []InstanceEvent{{Type: "restart", OldValue: "2022-10-01 10:00:00", NewValue: "2022-10-02 10:00:00"}},
+		},
+		{
+			name: "switchover",
+			prev: prev,
+			cur: func(s instanceSnapshot) instanceSnapshot {
+				s.DatabaseRole = "PHYSICAL STANDBY"
+				s.OpenMode = "MOUNTED"
+				s.Status = "MOUNTED"
+				return s
+			},
+			want: []InstanceEvent{
+				{Type: "role_change", OldValue: "PRIMARY", NewValue: "PHYSICAL STANDBY"},
+				{Type: "open_mode_change", OldValue: "READ WRITE", NewValue: "MOUNTED"},
+				{Type: "status_change", OldValue: "OPEN", NewValue: "MOUNTED"},
+			},
+		},
+		{
+			name: "upgrade",
+			prev: prev,
+			cur: func(s instanceSnapshot) instanceSnapshot {
+				s.StartupTime = "2022-10-02 10:00:00"
+				s.Version = "21.0.0.0.0"
+				return s
+			},
+			want: []InstanceEvent{
+				{Type: "restart", OldValue: "2022-10-01 10:00:00", NewValue: "2022-10-02 10:00:00"},
+				{Type: "version_change", OldValue: "19.0.0.0.0", NewValue: "21.0.0.0.0"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		if got := detectChanges(tt.prev, tt.cur(prev)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResetOnRestart(t *testing.T) {
+	oi := &OracleInstance{DiscoveredSid: "TEST", state: StateUp, instStatus: "OPEN", reconnecting: true}
+	oi.queryResult(context.DeadlineExceeded)
+	oi.queryResult(context.DeadlineExceeded)
+	oi.resetOnRestart()
+	// timeouts before the restart should not count to degrade the instance
+	oi.queryResult(context.DeadlineExceeded)
+	if s, _ := oi.GetState(); s != StateUp {
+		t.Errorf("got state %s, want %s", s, StateUp)
+	}
+}
@@ -0,0 +1,176 @@
+package oracle
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/toni-moreno/oracle_collector/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+// chFileChanged triggers a new discovery when a target file changes
+var chFileChanged = make(chan bool, 1)
+
+// ReadTargetFile reads the list of targets from a JSON or YAML (.yml/.yaml) file
+func ReadTargetFile(filename string) ([]*config.TargetConfig, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	targets := []*config.TargetConfig{}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(data, &targets)
+	default:
+		err = json.Unmarshal(data, &targets)
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	secrets := []string{}
+	for i, t := range targets {
+		// credentials can be references to env vars, files or secrets
+		found, err := config.ResolveValues(t, fmt.Sprintf("target[%d]", i))
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, found...)
+		if err := t.Validate(); err != nil {
+			return nil, err
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("target name %s is duplicated", t.Name)
+		}
+		names[t.Name] = true
+	}
+	config.SetSecrets("file:"+filename, secrets)
+	return targets, nil
+}
+
+// fileProvider reads the targets from the oracle_discovery_files
+type fileProvider struct {
+	// last valid targets read from each file
+	targets map[string][]*config.TargetConfig
+}
+
+func (fp *fileProvider) Name() string {
+	return "file"
+}
+
+func (fp *fileProvider) Enabled(cfg *config.DiscoveryConfig) bool {
+	return len(cfg.OracleDiscoveryFiles) > 0
+}
+
+// Discover returns the targets in all files matching the patterns, files
+// with errors keep its last valid targets
+func (fp *fileProvider) Discover(cfg *config.DiscoveryConfig) ([]*DiscoveredTarget, error) {
+	targets := []*DiscoveredTarget{}
+	found := make(map[string]bool)
+	errs := []string{}
+	for _, p := range cfg.OracleDiscoveryFiles {
+		files, _ := filepath.Glob(p)
+		for _, f := range files {
+			found[f] = true
+			ft, err := ReadTargetFile(f)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s (keeping %d previous targets): %s", f, len(fp.targets[f]), err))
+			} else {
+				fp.targets[f] = ft
+			}
+			for _, t := range fp.targets[f] {
+				targets = append(targets, &DiscoveredTarget{ID: t.Name, Target: t})
+			}
+		}
+	}
+	// removed files
+	for f := range fp.targets {
+		if !found[f] {
+			delete(fp.targets, f)
+			config.SetSecrets("file:"+f, nil)
+		}
+	}
+	if len(errs) > 0 {
+		return targets, fmt.Errorf("Error on reading targets files: %s", strings.Join(errs, ", "))
+	}
+	return targets, nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if match, _ := filepath.Match(p, name); match {
+			return true
+		}
+	}
+	return false
+}
+
+// startFileWatcher watches the dirs of the target files and triggers
+// a new discovery on any change in the files matching the patterns
+func startFileWatcher(patterns []string) (*fsnotify.Watcher, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// files are usually replaced (not written): dirs should be watched
+	dirs := make(map[string]bool)
+	for _, p := range patterns {
+		dirs[filepath.Dir(p)] = true
+	}
+	for d := range dirs {
+		if err := w.Add(d); err != nil {
+			w.Close()
+			return nil, fmt.Errorf("Error on watching dir %s: %s", d, err)
+		}
+	}
+	go func() {
+		for {
+			select {
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if ev.Op == fsnotify.Chmod || !matchAny(patterns, ev.Name) {
+					continue
+				}
+				log.Infof("[DISCOVERY] Targets file %s changed (%s)", ev.Name, ev.Op)
+				select {
+				case chFileChanged <- true:
+				default:
+				}
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				log.Warnf("[DISCOVERY] Error on watching targets files: %s", err)
+			}
+		}
+	}()
+	return w, nil
+}
+
+// targetChanged checks if the connection parameters of the discovered target
+// are not the ones used by the running instance
+func targetChanged(inst *OracleInstance, instances []*OracleInstance) bool {
+	if inst.Target == nil {
+		return false
+	}
+	for _, t := range instances {
+		if t.Target == nil || t.DiscoveredSid != inst.DiscoveredSid {
+			continue
+		}
+		return t.Target.OracleConnectDSN != inst.Target.OracleConnectDSN ||
+			t.Target.OracleConnectUser != inst.Target.OracleConnectUser ||
+			t.Target.OracleConnectPass != inst.Target.OracleConnectPass ||
+			t.Target.OracleAuthMode != inst.Target.OracleAuthMode ||
+			t.Target.AdminRole != inst.Target.AdminRole
+	}
+	return false
+}
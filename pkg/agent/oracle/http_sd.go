@@ -0,0 +1,111 @@
+package oracle
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/toni-moreno/oracle_collector/pkg/config"
+)
+
+// httpSDNameLabel is the HTTP SD meta label with the target name, other
+// target settings (credentials, auth mode, admin role) are only read from
+// the local config (dynamic-params matched by the target name)
+const httpSDNameLabel = "__oracle_name"
+
+// HTTPSDGroup is a target group in the Prometheus http_sd format
+type HTTPSDGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// ReadHTTPSD gets the targets from the HTTP SD endpoint, each target is a DSN
+// named by the __oracle_name label (or the DSN if not set), the labels starting
+// with __ are not added to the instance labels. All values are literals.
+func ReadHTTPSD(url string, timeout time.Duration) ([]*config.TargetConfig, error) {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	groups := []*HTTPSDGroup{}
+	err = json.Unmarshal(body, &groups)
+	if err != nil {
+		return nil, err
+	}
+	targets := []*config.TargetConfig{}
+	names := make(map[string]bool)
+	for i, g := range groups {
+		labels := make(map[string]string)
+		for k, v := range g.Labels {
+			switch {
+			case !strings.HasPrefix(k, "__"):
+				labels[k] = v
+			case strings.HasPrefix(k, "__oracle_") && k != httpSDNameLabel:
+				log.Warnf("[DISCOVERY] HTTP SD: group[%d]: label %s ignored, target settings are only read from dynamic-params", i, k)
+			}
+		}
+		if len(g.Labels[httpSDNameLabel]) > 0 && len(g.Targets) > 1 {
+			return nil, fmt.Errorf("group[%d]: label %s can only be set on groups with one target", i, httpSDNameLabel)
+		}
+		for _, dsn := range g.Targets {
+			t := &config.TargetConfig{
+				Name:             dsn,
+				OracleConnectDSN: dsn,
+				ExtraLabels:      labels,
+			}
+			if len(g.Labels[httpSDNameLabel]) > 0 {
+				t.Name = g.Labels[httpSDNameLabel]
+			}
+			if err := t.Validate(); err != nil {
+				return nil, err
+			}
+			if names[t.Name] {
+				return nil, fmt.Errorf("target name %s is duplicated", t.Name)
+			}
+			names[t.Name] = true
+			targets = append(targets, t)
+		}
+	}
+	return targets, nil
+}
+
+// httpSDProvider reads the targets from the oracle_discovery_http_sd_url
+type httpSDProvider struct {
+	// last valid targets
+	targets []*config.TargetConfig
+}
+
+func (hp *httpSDProvider) Name() string {
+	return "http_sd"
+}
+
+func (hp *httpSDProvider) Enabled(cfg *config.DiscoveryConfig) bool {
+	return len(cfg.OracleDiscoveryHTTPSDURL) > 0
+}
+
+// Discover returns the HTTP SD targets, on errors the last valid ones are returned
+func (hp *httpSDProvider) Discover(cfg *config.DiscoveryConfig) ([]*DiscoveredTarget, error) {
+	targets, err := ReadHTTPSD(cfg.OracleDiscoveryHTTPSDURL, cfg.OracleDiscoveryHTTPSDTimeout)
+	if err != nil {
+		err = fmt.Errorf("Error on reading HTTP SD targets from %s (keeping %d previous targets): %s", config.Redact(cfg.OracleDiscoveryHTTPSDURL), len(hp.targets), config.Redact(err.Error()))
+	} else {
+		hp.targets = targets
+	}
+	ret := []*DiscoveredTarget{}
+	for _, t := range hp.targets {
+		ret = append(ret, &DiscoveredTarget{ID: t.Name, Target: t})
+	}
+	return ret, err
+}
@@ -0,0 +1,133 @@
+package oracle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/toni-moreno/oracle_collector/pkg/config"
+)
+
+// sdServer answers with the current status and body
+type sdServer struct {
+	sync.Mutex
+	status int
+	body   string
+}
+
+func (ss *sdServer) set(status int, body string) {
+	ss.Lock()
+	defer ss.Unlock()
+	ss.status = status
+	ss.body = body
+}
+
+func (ss *sdServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ss.Lock()
+	defer ss.Unlock()
+	w.WriteHeader(ss.status)
+	w.Write([]byte(ss.body))
+}
+
+func TestReadHTTPSD(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []config.TargetConfig
+		fail bool
+	}{
+		{
+			name: "labels",
+			body: `[{"targets":["db1:1521/PRO1","db2:1521/PRO2"],"labels":{"env":"PRO","__meta_zone":"eu"}}]`,
+			want: []config.TargetConfig{
+				{Name: "db1:1521/PRO1", OracleConnectDSN: "db1:1521/PRO1", ExtraLabels: map[string]string{"env": "PRO"}},
+				{Name: "db2:1521/PRO2", OracleConnectDSN: "db2:1521/PRO2", ExtraLabels: map[string]string{"env": "PRO"}},
+			},
+		},
+		{
+			name: "target name",
+			body: `[{"targets":["db3:1521/DEV"],"labels":{"env":"DEV","__oracle_name":"DEV"}}]`,
+			want: []config.TargetConfig{
+				{Name: "DEV", OracleConnectDSN: "db3:1521/DEV", ExtraLabels: map[string]string{"env": "DEV"}},
+			},
+		},
+		{
+			name: "settings labels are literals and ignored",
+			body: `[{"targets":["db4:1521/TST"],"labels":{"env":"${HOME}","__oracle_connect_user":"sys","__oracle_connect_pass":"file:/etc/passwd","__oracle_auth_mode":"os","__oracle_admin_role":"SYSDBA"}}]`,
+			want: []config.TargetConfig{
+				{Name: "db4:1521/TST", OracleConnectDSN: "db4:1521/TST", ExtraLabels: map[string]string{"env": "${HOME}"}},
+			},
+		},
+		{
+			name: "target name on group with several targets",
+			body: `[{"targets":["db1:1521/PRO1","db2:1521/PRO2"],"labels":{"__oracle_name":"PRO"}}]`,
+			fail: true,
+		},
+		{
+			name: "duplicated target name",
+			body: `[{"targets":["db1:1521/PRO1"]},{"targets":["db2:1521/PRO1"],"labels":{"__oracle_name":"db1:1521/PRO1"}}]`,
+			fail: true,
+		},
+		{
+			name: "invalid body",
+			body: `{"targets":["db1:1521/PRO1"]}`,
+			fail: true,
+		},
+	}
+	ss := &sdServer{}
+	ts := httptest.NewServer(ss)
+	defer ts.Close()
+	for _, tt := range tests {
+		ss.set(http.StatusOK, tt.body)
+		targets, err := ReadHTTPSD(ts.URL, time.Second)
+		if (err != nil) != tt.fail {
+			t.Errorf("%s: got error %v, want error %t", tt.name, err, tt.fail)
+			continue
+		}
+		got := []config.TargetConfig{}
+		for _, tc := range targets {
+			got = append(got, *tc)
+		}
+		if !tt.fail && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPSDProviderKeepsTargets(t *testing.T) {
+	ss := &sdServer{}
+	ts := httptest.NewServer(ss)
+	defer ts.Close()
+	cfg := &config.DiscoveryConfig{OracleDiscoveryHTTPSDURL: ts.URL, OracleDiscoveryHTTPSDTimeout: time.Second}
+	hp := &httpSDProvider{}
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   []string
+		fail   bool
+	}{
+		{"first read", http.StatusOK, `[{"targets":["db1:1521/PRO1","db2:1521/PRO2"]}]`, []string{"db1:1521/PRO1", "db2:1521/PRO2"}, false},
+		{"bad status", http.StatusInternalServerError, `error`, []string{"db1:1521/PRO1", "db2:1521/PRO2"}, true},
+		{"bad body", http.StatusOK, `[{"targets":`, []string{"db1:1521/PRO1", "db2:1521/PRO2"}, true},
+		{"new targets", http.StatusOK, `[{"targets":["db3:1521/DEV"]}]`, []string{"db3:1521/DEV"}, false},
+		{"empty list", http.StatusOK, `[]`, []string{}, false},
+	}
+	for _, tt := range tests {
+		ss.set(tt.status, tt.body)
+		targets, err := hp.Discover(cfg)
+		if (err != nil) != tt.fail {
+			t.Errorf("%s: got error %v, want error %t", tt.name, err, tt.fail)
+		}
+		got := []string{}
+		for _, dt := range targets {
+			got = append(got, dt.ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
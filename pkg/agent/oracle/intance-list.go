@@ -121,7 +121,7 @@ func (il *InstanceList) Delete(inst *OracleInstance) error {
 	}
 	if idx == -1 {
 		// not found
-		return fmt.Errorf("Error Deleting Instance [%] ,from Orcle List : NOT FOUND", inst.DiscoveredSid)
+		return fmt.Errorf("Error Deleting Instance [%s] ,from Orcle List : NOT FOUND", inst.DiscoveredSid)
 	}
 
 	il.OraInstances = remove(il.OraInstances, idx)
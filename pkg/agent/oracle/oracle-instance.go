@@ -3,10 +3,11 @@ package oracle
 import (
 	"context"
 	"database/sql"
-	"errors"
+	"database/sql/driver"
 	"fmt"
 	"net/url"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -15,7 +16,7 @@ import (
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/metric"
 
-	_ "github.com/godror/godror"
+	"github.com/godror/godror"
 	"github.com/sirupsen/logrus"
 
 	"github.com/hashicorp/go-version"
@@ -99,10 +100,26 @@ type OracleInstance struct {
 	ListenerIP   string
 	ListenerPort int
 	PMONpid      int32
+	OracleSid    string               // SID (DiscoveredSid can be SID@USER)
+	InstanceType string               // database, asm or apx
+	OSUser       string               // PMON process owner
+	OracleHome   string               // from the process HOME group or oratab
+	Target       *config.TargetConfig // only for static targets (no local PMON)
 	cfg          *config.DiscoveryConfig
 	conn         *sql.DB
 	log          *logrus.Logger
 	labels       map[string]string
+	params       map[string]string // metric params from dynamic-params
+	// health state (see state.go)
+	stateMutex   sync.Mutex
+	state        InstanceState
+	stateSince   time.Time
+	instStatus   string // last v$instance STATUS
+	timeouts     int    // consecutive query timeouts
+	reconnecting bool
+	reconnects   int
+	done         chan bool // closed on End
+	ended        bool
 }
 
 func (oi *OracleInstance) String() string {
@@ -121,6 +138,29 @@ func (oi *OracleInstance) GetIsValidForDBQuery() bool {
 	return oi.IsValidForDBQuery
 }
 
+// GetStatus returns the V$INSTANCE status (STARTED, MOUNTED, OPEN...)
+func (oi *OracleInstance) GetStatus() string {
+	oi.Lock()
+	defer oi.Unlock()
+	return oi.InstInfo.Status
+}
+
+// GetSid returns the instance SID (the target name for remote targets)
+func (oi *OracleInstance) GetSid() string {
+	if len(oi.OracleSid) > 0 {
+		return oi.OracleSid
+	}
+	return oi.DiscoveredSid
+}
+
+// GetInstanceType returns database, asm or apx
+func (oi *OracleInstance) GetInstanceType() string {
+	if len(oi.InstanceType) > 0 {
+		return oi.InstanceType
+	}
+	return InstanceTypeDatabase
+}
+
 func (oi *OracleInstance) GetInstanceName() string {
 	oi.Lock()
 	defer oi.Unlock()
@@ -142,6 +182,7 @@ func (oi *OracleInstance) initExtraLabels() map[string]string {
 	// Dinamic labels.
 	SID := oi.InstInfo.InstName // oi.Discovered SID
 
+	oi.params = make(map[string]string)
 	for n, rule := range oi.cfg.DynamicParamsBySID {
 		oi.log.Debugf("EXTRA LABELS: Applying rule [%d] info with sid_regex = %s", n, rule.SidRegex)
 		match := rule.R.MatchString(SID)
@@ -149,8 +190,20 @@ func (oi *OracleInstance) initExtraLabels() map[string]string {
 			for k, v := range rule.ExtraLabels {
 				oi.labels[k] = v
 			}
+			for k, v := range rule.Params {
+				oi.params[k] = v
+			}
+		}
+	}
+	// Target labels
+	if oi.Target != nil {
+		for k, v := range oi.Target.ExtraLabels {
+			oi.labels[k] = v
 		}
 	}
+	if len(oi.OracleHome) > 0 {
+		oi.labels["oracle_home"] = oi.OracleHome
+	}
 	// Oracle Mandatory Labels.
 
 	oi.labels["instance"] = oi.InstInfo.InstName
@@ -160,40 +213,174 @@ func (oi *OracleInstance) initExtraLabels() map[string]string {
 	return oi.labels
 }
 
-func (oi *OracleInstance) Query(timeout time.Duration, query string, t *data.DataTable) (int, time.Duration, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-	start := time.Now()
-	rows, err := oi.conn.QueryContext(ctx, query) // DATA RACE FOUND
+// sessionModule is the MODULE of all collector sessions (v$session)
+const sessionModule = "oracle_collector"
+
+// SessionInfo identifies the running query in v$session and has the
+// statements to set up and restore the session
+type SessionInfo struct {
+	Action     string   // metric id
+	ClientInfo string   // metric group
+	Init       []string // session_init statements
+	Reset      []string // session_reset statements
+}
+
+// limit truncates s to the max size of the v$session column
+func limit(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
+
+// context adds the MODULE, ACTION and CLIENT_INFO to the query context
+func (si SessionInfo) context(ctx context.Context) context.Context {
+	return godror.ContextWithTraceTag(ctx, godror.TraceTag{
+		Module:     sessionModule,
+		Action:     limit(si.Action, 32),
+		ClientInfo: limit(si.ClientInfo, 64),
+	})
+}
+
+// queryer is implemented by the connection pool (sql.DB) and dedicated connections (sql.Conn)
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func queryTable(ctx context.Context, q queryer, query string, args []interface{}, t *data.DataTable) (int, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	// errors are wrapped to check timeouts and lost connections (see state.go)
 	if ctx.Err() == context.DeadlineExceeded {
-		return 0, 0, errors.New("Oracle query timed out")
+		return 0, fmt.Errorf("Oracle query timed out: %w", ctx.Err())
 	}
 	if err != nil {
-		elapsed := time.Since(start)
-		return 0, elapsed, fmt.Errorf("Error in instance Query:%s", err)
+		return 0, fmt.Errorf("Error in instance Query:%w", err)
 	}
 	defer rows.Close()
 	c, err := rows.Columns()
 	if err != nil {
-		elapsed := time.Since(start)
-		return 0, elapsed, fmt.Errorf("Error on Query Columns:%s", err)
+		return 0, fmt.Errorf("Error on Query Columns:%s", err)
 	}
 	t.SetHeader(c)
 
 	for rows.Next() {
 		rowpointers := t.AppendEmptyRow()
 		if err := rows.Scan(rowpointers...); err != nil {
-			elapsed := time.Since(start)
-			return 0, elapsed, err
+			return 0, err
+		}
+	}
+	// the timeout could also expire while fetching rows
+	if err := rows.Err(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return 0, fmt.Errorf("Oracle query timed out: %w", ctx.Err())
+		}
+		return 0, fmt.Errorf("Error on Query rows:%w", err)
+	}
+	return t.Length(), nil
+}
+
+func (oi *OracleInstance) Query(timeout time.Duration, si SessionInfo, query string, args []interface{}, t *data.DataTable) (int, time.Duration, error) {
+	// session settings need a dedicated connection
+	if len(si.Init) > 0 {
+		return oi.queryOnConn(timeout, si, "", query, args, t)
+	}
+	ctx, cancel := context.WithTimeout(si.context(context.Background()), timeout)
+	defer cancel()
+	start := time.Now()
+	n, err := queryTable(ctx, oi.getConn(), query, args, t)
+	oi.queryResult(err)
+	return n, time.Since(start), err
+}
+
+// QueryPDB runs the query inside the PDB container on a dedicated connection,
+// which is switched back to the root container before returning it to the pool
+func (oi *OracleInstance) QueryPDB(timeout time.Duration, si SessionInfo, pdb string, query string, args []interface{}, t *data.DataTable) (int, time.Duration, error) {
+	return oi.queryOnConn(timeout, si, pdb, query, args, t)
+}
+
+// queryOnConn runs the query on a dedicated connection switched to the pdb container
+// (if any) and with the session init statements, the session is restored before
+// returning the connection to the pool (or the connection is discarded)
+func (oi *OracleInstance) queryOnConn(timeout time.Duration, si SessionInfo, pdb string, query string, args []interface{}, t *data.DataTable) (int, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(si.context(context.Background()), timeout)
+	defer cancel()
+	start := time.Now()
+	c, err := oi.getConn().Conn(ctx)
+	if err != nil {
+		oi.queryResult(err)
+		return 0, time.Since(start), fmt.Errorf("Error on getting connection: %s", err)
+	}
+	defer c.Close()
+	if len(pdb) > 0 {
+		_, err = c.ExecContext(ctx, `ALTER SESSION SET CONTAINER = "`+strings.ReplaceAll(pdb, `"`, ``)+`"`)
+		if err != nil {
+			oi.queryResult(err)
+			return 0, time.Since(start), fmt.Errorf("Error on switching to container %s: %s", pdb, err)
+		}
+	}
+	for _, stmt := range si.Init {
+		_, err = c.ExecContext(ctx, stmt)
+		if err != nil {
+			oi.queryResult(err)
+			// session partially initialized
+			c.Raw(func(interface{}) error { return driver.ErrBadConn })
+			return 0, time.Since(start), fmt.Errorf("Error on session init [%s]: %s", stmt, err)
 		}
 	}
+	n, err := queryTable(ctx, c, query, args, t)
+	oi.queryResult(err)
 	elapsed := time.Since(start)
-	return t.Length(), elapsed, nil
+	// the query context could be expired
+	rctx, rcancel := context.WithTimeout(context.Background(), timeout)
+	defer rcancel()
+	if !oi.resetSession(rctx, c, si, pdb) {
+		c.Raw(func(interface{}) error { return driver.ErrBadConn })
+	}
+	return n, elapsed, err
+}
+
+// resetSession restores the session changed by queryOnConn, returns false if
+// the connection should be discarded
+func (oi *OracleInstance) resetSession(ctx context.Context, c *sql.Conn, si SessionInfo, pdb string) bool {
+	// session_reset is mandatory with session_init (see config validation)
+	if len(si.Init) > 0 && len(si.Reset) == 0 {
+		oi.log.Warnf("Discarding connection: session_init without session_reset")
+		return false
+	}
+	for _, stmt := range si.Reset {
+		if _, err := c.ExecContext(ctx, stmt); err != nil {
+			oi.log.Warnf("Error on session reset [%s], discarding connection: %s", stmt, err)
+			return false
+		}
+	}
+	if len(pdb) > 0 {
+		if _, err := c.ExecContext(ctx, `ALTER SESSION SET CONTAINER = CDB$ROOT`); err != nil {
+			oi.log.Warnf("Error on switching back to root container from %s, discarding connection: %s", pdb, err)
+			return false
+		}
+	}
+	return true
 }
 
+func (oi *OracleInstance) getConn() *sql.DB {
+	oi.Lock()
+	defer oi.Unlock()
+	return oi.conn
+}
+
+// GetPDBs returns the PDBs info from the last update (empty on non CDB databases)
+func (oi *OracleInstance) GetPDBs() []PdbInfo {
+	oi.Lock()
+	defer oi.Unlock()
+	return oi.DBInfo.PDBs
+}
+
+var logNameRegex = regexp.MustCompile(`[^\w.-]`)
+
 func CreateLoggerForSid(sid string, loglevel string) *logrus.Logger {
 	log := logrus.New()
-	logfilename := logDir + "/collector_" + sid + ".log"
+	// target names can be DSNs (HTTP SD)
+	logfilename := logDir + "/collector_" + logNameRegex.ReplaceAllString(sid, "_") + ".log"
 	f, _ := os.OpenFile(logfilename, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o644)
 	log.Out = f
 	l, _ := logrus.ParseLevel(loglevel)
@@ -202,6 +389,7 @@ func CreateLoggerForSid(sid string, loglevel string) *logrus.Logger {
 	customFormatter.TimestampFormat = "2006-01-02 15:04:05"
 	log.Formatter = customFormatter
 	customFormatter.FullTimestamp = true
+	log.AddHook(config.NewRedactHook())
 	return log
 }
 
@@ -212,7 +400,7 @@ func (oi *OracleInstance) GetVersion() error {
 	log.Infof("[DISCOVERY] Get Version Instance Info...")
 	query := "select VERSION from V$INSTANCE"
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(SessionInfo{Action: "instance_info"}.context(context.Background()), 10*time.Second)
 	defer cancel()
 
 	row := oi.conn.QueryRowContext(ctx, query)
@@ -252,7 +440,28 @@ func (oi *OracleInstance) CheckVersionBetween(lower_ver, upper_ver string) (stri
 	return oi.InitVersion.String(), (oi.InitVersion.GreaterThanOrEqual(l) && oi.InitVersion.LessThan(u))
 }
 
+// UpdateInfo gets the instance, database and PDBs info, updates the instance
+// state and sends the oracle_events for the changes since the last update
 func (oi *OracleInstance) UpdateInfo() error {
+	oi.Lock()
+	prev := oi.snapshot()
+	oi.Unlock()
+	err := oi.updateInfo()
+	oi.Lock()
+	status := oi.InstInfo.Status
+	var events []InstanceEvent
+	if err == nil {
+		events = detectChanges(prev, oi.snapshot())
+	}
+	oi.Unlock()
+	oi.infoResult(status, err)
+	if len(events) > 0 {
+		oi.sendEvents(events)
+	}
+	return err
+}
+
+func (oi *OracleInstance) updateInfo() error {
 	oi.Lock()
 	defer oi.Unlock()
 	// Initialize instance Data.
@@ -302,12 +511,12 @@ func (oi *OracleInstance) UpdateInfo() error {
 		`
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(SessionInfo{Action: "instance_info"}.context(context.Background()), 10*time.Second)
 	defer cancel()
 
 	rows_i, err := oi.conn.QueryContext(ctx, query)
 	if ctx.Err() == context.DeadlineExceeded {
-		return errors.New("Oracle Info query timed out")
+		return fmt.Errorf("Oracle Info query timed out: %w", ctx.Err())
 	}
 	if err != nil {
 		log.Warnf("[DISCOVERY] Error in instance Query:%s", err)
@@ -510,20 +719,52 @@ func (oi *OracleInstance) UpdateInfo() error {
 func (oi *OracleInstance) Init(loglevel string, ClusterwareEnabled bool, StatusExtendedInfo bool) error {
 	var err error
 
+	if oi.Target != nil {
+		ClusterwareEnabled = oi.Target.ClusterwareEnabled
+	}
 	oi.ClusteWareEnabled = ClusterwareEnabled
 	oi.StatusExtendedInfo = StatusExtendedInfo
 
-	oi.log = CreateLoggerForSid(oi.DiscoveredSid, loglevel)
+	// pending instances keep its logger on retries
+	if oi.log == nil {
+		oi.log = CreateLoggerForSid(oi.DiscoveredSid, loglevel)
+	}
+
+	oi.stateMutex.Lock()
+	oi.state = StateConnecting
+	oi.stateSince = time.Now()
+	oi.stateMutex.Unlock()
+	if oi.done == nil {
+		oi.done = make(chan bool)
+	}
+	err = oi.connect()
+	if err != nil {
+		return err
+	}
+	oi.GetVersion()
+	return oi.UpdateInfo()
+}
+
+// connect opens a new connection pool to the instance (replacing the current one)
+func (oi *OracleInstance) connect() error {
+	// config and target can be updated while reconnecting
+	oi.Lock()
+	cfg := oi.cfg
+	target := oi.Target
+	oi.Unlock()
 
 	// Get Initializacion parametres
 
-	ConnectDSN := oi.cfg.OracleConnectDSN
-	ConnectUser := oi.cfg.OracleConnectUser
-	ConnectPass := oi.cfg.OracleConnectPass
+	ConnectDSN := cfg.OracleConnectDSN
+	ConnectUser := cfg.OracleConnectUser
+	ConnectPass := cfg.OracleConnectPass
+	AuthMode := cfg.OracleAuthMode
+	AdminRole := ""
+	pool := cfg.PoolConfig
 
-	for n, rule := range oi.cfg.DynamicParamsBySID {
+	for n, rule := range cfg.DynamicParamsBySID {
 		log.Debugf("ORACLE INIT: Applying rule [%d] info with sid_regex = %s", n, rule.SidRegex)
-		match := rule.R.MatchString(oi.DiscoveredSid)
+		match := rule.R.MatchString(oi.GetSid())
 		if match {
 			log.Infof("ORACLE INIT: Dinamic params match at rule %d:[%s] ", n, rule.SidRegex)
 			if len(rule.OracleConnectDSN) > 0 {
@@ -535,43 +776,149 @@ func (oi *OracleInstance) Init(loglevel string, ClusterwareEnabled bool, StatusE
 			if len(rule.OracleConnectPass) > 0 {
 				ConnectPass = rule.OracleConnectPass
 			}
+			if len(rule.OracleAuthMode) > 0 {
+				AuthMode = rule.OracleAuthMode
+			}
+			if len(rule.AdminRole) > 0 {
+				AdminRole = rule.AdminRole
+			}
+			pool = pool.Merge(rule.PoolConfig)
 		}
 	}
 
-	dsn := strings.ReplaceAll(ConnectDSN, "SID", oi.DiscoveredSid)
-	connStr := "oracle://" + url.QueryEscape(ConnectUser) + ":" + url.QueryEscape(ConnectPass) + "@" + dsn
-	oi.Lock()
-	oi.conn, err = sql.Open("godror", connStr)
+	dsn := strings.ReplaceAll(ConnectDSN, "SID", oi.GetSid())
+	if target != nil {
+		dsn = target.OracleConnectDSN
+		if len(target.OracleConnectUser) > 0 {
+			ConnectUser = target.OracleConnectUser
+		}
+		if len(target.OracleConnectPass) > 0 {
+			ConnectPass = target.OracleConnectPass
+		}
+		if len(target.OracleAuthMode) > 0 {
+			AuthMode = target.OracleAuthMode
+		}
+		if len(target.AdminRole) > 0 {
+			AdminRole = target.AdminRole
+		}
+	}
+	var connStr string
+	switch AuthMode {
+	case config.AuthModeWallet, config.AuthModeOS:
+		// without user and password godror uses external authentication: credentials
+		// from the wallet entry for the DSN or the OS user
+		connStr = "oracle://@" + dsn
+	default:
+		if len(ConnectUser) == 0 || len(ConnectPass) == 0 {
+			return fmt.Errorf("ConnectDNS: %s: oracle_connect_user and oracle_connect_pass are mandatory with password authentication", dsn)
+		}
+		connStr = "oracle://" + url.QueryEscape(ConnectUser) + ":" + url.QueryEscape(ConnectPass) + "@" + dsn
+	}
+	params, err := godror.ParseDSN(connStr)
 	if err != nil {
-		log.Warnf("[DISCOVERY] Can't create connection: %s ", err)
-		oi.Unlock()
-		return fmt.Errorf("ConnectDNS: %s: ERR: %s", dsn, err)
-	}
-	log.Tracef("[DISCOVERY] Connection String: %s", connStr)
-	oi.conn.SetConnMaxLifetime(0)
-	oi.conn.SetMaxIdleConns(10)
-	oi.conn.SetMaxOpenConns(10)
+		log.Warnf("[DISCOVERY] Can't create connection: %s ", config.Redact(err.Error()))
+		return fmt.Errorf("ConnectDNS: %s: ERR: %s", dsn, config.Redact(err.Error()))
+	}
+	// TNS_ADMIN: only the first connection sets it for the Oracle client
+	params.ConfigDir = cfg.OracleTNSAdmin
+	// administrative connections are not pooled by the driver
+	switch AdminRole {
+	case "SYSDBA":
+		params.IsSysDBA = true
+	case "SYSOPER":
+		params.IsSysOper = true
+	case "SYSASM":
+		params.IsSysASM = true
+	}
+	if pool.PoolMode == "standalone" {
+		params.StandaloneConnection = true
+	} else {
+		// driver session pool limits
+		params.MaxSessions = pool.MaxOpenConns
+		if pool.ConnMaxLifetime > 0 {
+			params.MaxLifeTime = pool.ConnMaxLifetime
+		}
+		if pool.ConnMaxIdleTime > 0 {
+			params.SessionTimeout = pool.ConnMaxIdleTime
+		}
+	}
+	log.Tracef("[DISCOVERY] Connection Params (auth mode %s, admin role %s): %s", AuthMode, AdminRole, config.Redact(params.String()))
+	conn := sql.OpenDB(godror.NewConnector(params))
+	log.Debugf("[DISCOVERY] Connection pool (%s): max open %d, max idle %d, max lifetime %s, max idle time %s", pool.PoolMode, pool.MaxOpenConns, pool.MaxIdleConns, pool.ConnMaxLifetime, pool.ConnMaxIdleTime)
+	conn.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	conn.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	conn.SetMaxIdleConns(pool.MaxIdleConns)
+	conn.SetMaxOpenConns(pool.MaxOpenConns)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	// Connection Ping
-	err = oi.conn.PingContext(ctx)
+	err = conn.PingContext(ctx)
 	if ctx.Err() == context.DeadlineExceeded {
-		oi.Unlock()
-		return fmt.Errorf("ConnectDNS: %s: Oracle Ping timed out")
+		conn.Close()
+		return fmt.Errorf("ConnectDNS: %s: Oracle Ping timed out", dsn)
 	}
 	if err != nil {
 		log.Warnf("[DISCOVERY] Can't ping connection: %s ", err)
-		oi.Unlock()
-		return fmt.Errorf("ConnectDNS: %s: ERR: %s", dsn, err)
+		conn.Close()
+		return fmt.Errorf("ConnectDNS: %s: ERR: %s", dsn, config.Redact(err.Error()))
 	}
-	oi.Unlock()
-	oi.GetVersion()
-	return oi.UpdateInfo()
+	oi.Lock()
+	defer oi.Unlock()
+	if oi.ended {
+		conn.Close()
+		return fmt.Errorf("ConnectDNS: %s: instance removed", dsn)
+	}
+	// old connections (if reconnecting) are closed when released by running queries
+	if oi.conn != nil {
+		oi.conn.Close()
+	}
+	oi.conn = conn
+	return nil
+}
+
+// SetOracleHome sets the ORACLE_HOME from oratab (label updated on UpdateInfo)
+func (oi *OracleInstance) SetOracleHome(home string) {
+	oi.Lock()
+	defer oi.Unlock()
+	oi.OracleHome = home
+}
+
+// SetTarget updates the target settings and recomputes the instance labels,
+// connection parameters will only be used on new connections
+func (oi *OracleInstance) SetTarget(t *config.TargetConfig) {
+	oi.Lock()
+	defer oi.Unlock()
+	oi.Target = t
+	oi.initExtraLabels()
+}
+
+// UpdateConfig sets the new discovery config and recomputes the instance labels
+func (oi *OracleInstance) UpdateConfig(cfg *config.DiscoveryConfig) {
+	oi.Lock()
+	defer oi.Unlock()
+	oi.cfg = cfg
+	oi.StatusExtendedInfo = cfg.OracleStatusExtendedInfo
+	if oi.Target != nil {
+		for _, t := range cfg.StaticTargets {
+			if t.Name == oi.Target.Name {
+				oi.Target = t
+			}
+		}
+	}
+	oi.initExtraLabels()
 }
 
 func (oi *OracleInstance) End() error {
 	oi.Lock()
 	defer oi.Unlock()
+	if !oi.ended {
+		oi.ended = true
+		close(oi.done)
+	}
+	// pending instances could have no connection
+	if oi.conn == nil {
+		return nil
+	}
 	err := oi.conn.Close()
 	if err != nil {
 		log.Errorf("[DISCOVERY] Error while closing oracle connection: %s:", err)
@@ -579,6 +926,8 @@ func (oi *OracleInstance) End() error {
 	return nil
 }
 
+// StatusMetrics returns the oracle_status metric, process_ok is the connection
+// status for static targets (connect_ok field)
 func (oi *OracleInstance) StatusMetrics(process_ok bool) []telegraf.Metric {
 	tags := make(map[string]string)
 	// first added extra tags
@@ -586,9 +935,18 @@ func (oi *OracleInstance) StatusMetrics(process_ok bool) []telegraf.Metric {
 		tags[k] = v
 	}
 	fields := make(map[string]interface{})
-	// From system process
-	fields["proc_ok"] = process_ok
-	fields["proc_pid"] = oi.PMONpid
+	if oi.Target != nil {
+		fields["connect_ok"] = process_ok
+	} else {
+		// From system process
+		fields["proc_ok"] = process_ok
+		fields["proc_pid"] = oi.PMONpid
+		fields["proc_user"] = oi.OSUser
+	}
+	fields["inst_type"] = oi.GetInstanceType()
+	state, since := oi.GetState()
+	fields["state"] = string(state)
+	fields["state_duration_sec"] = int64(time.Since(since).Seconds())
 	// From v$instance
 
 	fields["inst_number"] = oi.InstInfo.InstNumber
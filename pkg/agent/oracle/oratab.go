@@ -0,0 +1,131 @@
+package oracle
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/toni-moreno/oracle_collector/pkg/config"
+)
+
+// OratabEntry is a database expected to be running from the oratab file
+type OratabEntry struct {
+	SID        string
+	OracleHome string
+	Startup    string // Y/N/W dbstart flag
+}
+
+// ReadOratab returns the database entries from the oratab file
+// (format: SID:ORACLE_HOME:Y|N), grid entries (+ASM,-MGMTDB) are skipped
+func ReadOratab(filename string) ([]*OratabEntry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries := []*OratabEntry{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		parts := strings.Split(line, ":")
+		if len(parts) < 2 || len(parts[0]) == 0 {
+			log.Warnf("[DISCOVERY] Oratab: invalid line in %s: %s", filename, line)
+			continue
+		}
+		sid := parts[0]
+		if sid == "*" || strings.HasPrefix(sid, "+") || strings.HasPrefix(sid, "-") {
+			continue
+		}
+		e := &OratabEntry{SID: sid, OracleHome: parts[1]}
+		if len(parts) > 2 {
+			e.Startup = parts[2]
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// oratabEntryFor returns the entry for the running SID, RAC instances
+// (SID + instance number) match the database entry if not found
+func oratabEntryFor(entries []*OratabEntry, sid string) *OratabEntry {
+	for _, e := range entries {
+		if e.SID == sid {
+			return e
+		}
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(sid, e.SID) && isDigits(sid[len(e.SID):]) {
+			return e
+		}
+	}
+	return nil
+}
+
+// isDigits checks if s is a non empty string of digits
+func isDigits(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// expected returns false for entries not started by dbstart (N flag)
+func (e *OratabEntry) expected() bool {
+	return !strings.EqualFold(e.Startup, "N")
+}
+
+// oratabNotRunning returns the entries expected to be running (Y/W flag)
+// without any running instance
+func oratabNotRunning(entries []*OratabEntry, running []*OracleInstance) []*OratabEntry {
+	found := make(map[*OratabEntry]bool)
+	for _, inst := range running {
+		if e := oratabEntryFor(entries, inst.GetSid()); e != nil {
+			found[e] = true
+		}
+	}
+	ret := []*OratabEntry{}
+	for _, e := range entries {
+		if !found[e] && e.expected() {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+// notRunningMetrics returns the oracle_status for an oratab database not running
+func notRunningMetrics(cfg *config.DiscoveryConfig, e *OratabEntry) []telegraf.Metric {
+	oi := &OracleInstance{
+		DiscoveredSid: e.SID,
+		OracleHome:    e.OracleHome,
+		cfg:           cfg,
+		log:           log,
+	}
+	oi.InstInfo.InstName = e.SID
+	tags := make(map[string]string)
+	// db info is unknown without connection
+	for k, v := range oi.initExtraLabels() {
+		if len(v) > 0 {
+			tags[k] = v
+		}
+	}
+	fields := make(map[string]interface{})
+	fields["proc_ok"] = false
+	fields["proc_pid"] = oi.PMONpid
+	fields["oratab_startup"] = e.Startup
+	return []telegraf.Metric{metric.New("oracle_status", tags, fields, time.Now())}
+}
@@ -0,0 +1,86 @@
+package oracle
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadOratab(t *testing.T) {
+	entries, err := ReadOratab("testdata/oratab")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []OratabEntry{
+		{SID: "PRO1", OracleHome: "/u01/app/oracle/product/19.0.0/dbhome_1", Startup: "Y"},
+		{SID: "PRO", OracleHome: "/u01/app/oracle/product/19.0.0/dbhome_1", Startup: "W"},
+		{SID: "TEST", OracleHome: "/u01/app/oracle/product/12.2.0/dbhome_1", Startup: "N"},
+		{SID: "DEV", OracleHome: "/u01/app/oracle/product/19.0.0/dbhome_2"},
+	}
+	got := []OratabEntry{}
+	for _, e := range entries {
+		got = append(got, *e)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if _, err := ReadOratab("testdata/none"); err == nil {
+		t.Errorf("expected error on missing file")
+	}
+}
+
+func TestOratabEntryFor(t *testing.T) {
+	entries, err := ReadOratab("testdata/oratab")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tests := []struct {
+		sid  string
+		want string
+	}{
+		{"PRO1", "PRO1"}, // exact match first
+		{"PRO2", "PRO"},  // RAC instance
+		{"PRO12", "PRO1"},
+		{"TEST1", "TEST"},
+		{"DEVX", ""},
+		{"DEV", "DEV"},
+		{"PROD", ""},
+		{"OTHER", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if e := oratabEntryFor(entries, tt.sid); e != nil {
+			got = e.SID
+		}
+		if got != tt.want {
+			t.Errorf("oratabEntryFor(%s): got [%s], want [%s]", tt.sid, got, tt.want)
+		}
+	}
+}
+
+func TestOratabNotRunning(t *testing.T) {
+	entries, err := ReadOratab("testdata/oratab")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tests := []struct {
+		running []string
+		want    []string
+	}{
+		{[]string{}, []string{"PRO1", "PRO", "DEV"}},
+		{[]string{"PRO1", "PRO2"}, []string{"DEV"}},
+		{[]string{"PRO11", "DEV", "TEST"}, []string{"PRO"}},
+	}
+	for _, tt := range tests {
+		running := []*OracleInstance{}
+		for _, sid := range tt.running {
+			running = append(running, &OracleInstance{OracleSid: sid})
+		}
+		got := []string{}
+		for _, e := range oratabNotRunning(entries, running) {
+			got = append(got, e.SID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("running %v: got %v, want %v", tt.running, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package oracle
+
+import (
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/toni-moreno/oracle_collector/pkg/config"
+)
+
+// retry backoff limits for instances failing to initialize
+const (
+	pendingMinBackoff = 10 * time.Second
+	pendingMaxBackoff = 10 * time.Minute
+)
+
+// PendingInstance is a discovered instance which failed to initialize
+type PendingInstance struct {
+	Inst         *OracleInstance
+	FirstFailure time.Time
+	LastError    error
+	Attempts     int
+	NextRetry    time.Time
+	backoff      time.Duration
+}
+
+// pending instances by SID, only used from the discovery process
+var pending = make(map[string]*PendingInstance)
+
+// pendingFor returns the pending instance for the discovered one (updated
+// with the last discovery info) or nil if not pending
+func pendingFor(inst *OracleInstance) *PendingInstance {
+	p, ok := pending[inst.DiscoveredSid]
+	if !ok {
+		return nil
+	}
+	// all the identity fields set by the discovery provider
+	p.Inst.Lock()
+	p.Inst.OracleSid = inst.OracleSid
+	p.Inst.InstanceType = inst.InstanceType
+	p.Inst.PMONpid = inst.PMONpid
+	p.Inst.OracleHome = inst.OracleHome
+	p.Inst.OSUser = inst.OSUser
+	p.Inst.Target = inst.Target
+	p.Inst.Unlock()
+	return p
+}
+
+// setPending adds the instance to the pending set or schedules its next retry
+func setPending(inst *OracleInstance, err error) *PendingInstance {
+	now := time.Now()
+	p, ok := pending[inst.DiscoveredSid]
+	if !ok {
+		p = &PendingInstance{Inst: inst, FirstFailure: now, backoff: pendingMinBackoff}
+		pending[inst.DiscoveredSid] = p
+	} else {
+		p.backoff *= 2
+		if p.backoff > pendingMaxBackoff {
+			p.backoff = pendingMaxBackoff
+		}
+	}
+	p.Attempts++
+	p.LastError = err
+	p.NextRetry = now.Add(p.backoff)
+	return p
+}
+
+// dropPending removes the pending instances not discovered anymore
+func dropPending(discovered []*OracleInstance) {
+	found := make(map[string]bool)
+	for _, inst := range discovered {
+		found[inst.DiscoveredSid] = true
+	}
+	for sid, p := range pending {
+		if !found[sid] {
+			log.Infof("[DISCOVERY] Pending instance %s is LOST (failing since %s)", sid, p.FirstFailure.Format(time.RFC3339))
+			p.Inst.End()
+			delete(pending, sid)
+		}
+	}
+}
+
+// GetPendingInstances returns the SIDs of the instances failing to initialize
+func GetPendingInstances() []string {
+	ret := []string{}
+	for sid := range pending {
+		ret = append(ret, sid)
+	}
+	return ret
+}
+
+// Due checks if the initialization should be retried
+func (p *PendingInstance) Due(now time.Time) bool {
+	return !now.Before(p.NextRetry)
+}
+
+// Metrics returns the oracle_status for the pending instance
+func (p *PendingInstance) Metrics() []telegraf.Metric {
+	oi := p.Inst
+	oi.Lock()
+	defer oi.Unlock()
+	if len(oi.InstInfo.InstName) == 0 {
+		oi.InstInfo.InstName = oi.GetSid()
+	}
+	tags := make(map[string]string)
+	// db info could be unknown without connection
+	for k, v := range oi.initExtraLabels() {
+		if len(v) > 0 {
+			tags[k] = v
+		}
+	}
+	fields := make(map[string]interface{})
+	fields["connect_ok"] = false
+	if oi.Target == nil {
+		fields["proc_ok"] = true
+		fields["proc_pid"] = oi.PMONpid
+		fields["proc_user"] = oi.OSUser
+	}
+	fields["inst_type"] = oi.GetInstanceType()
+	fields["state"] = string(StateConnecting)
+	fields["connect_error"] = config.Redact(p.LastError.Error())
+	fields["connect_attempts"] = p.Attempts
+	fields["connect_first_failure"] = p.FirstFailure.Format(time.RFC3339)
+	fields["connect_failing_sec"] = int64(time.Since(p.FirstFailure).Seconds())
+	return []telegraf.Metric{metric.New("oracle_status", tags, fields, time.Now())}
+}
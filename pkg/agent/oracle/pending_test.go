@@ -0,0 +1,31 @@
+package oracle
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPendingFor(t *testing.T) {
+	first := (&DiscoveredTarget{ID: "ORCL@oracle", SID: "ORCL", PMONpid: 100, OSUser: "oracle"}).newInstance()
+	setPending(first, errors.New("ORA-01017: invalid username/password"))
+	defer delete(pending, first.DiscoveredSid)
+
+	if p := pendingFor((&DiscoveredTarget{ID: "OTHER", SID: "OTHER"}).newInstance()); p != nil {
+		t.Errorf("unexpected pending instance for OTHER")
+	}
+	again := (&DiscoveredTarget{ID: "ORCL@oracle", SID: "ORCL", InstanceType: InstanceTypeASM, PMONpid: 200, OracleHome: "/u01/grid", OSUser: "oracle"}).newInstance()
+	p := pendingFor(again)
+	if p == nil {
+		t.Fatalf("pending instance not found")
+	}
+	if p.Inst != first {
+		t.Errorf("pending instance should be kept")
+	}
+	got := []interface{}{p.Inst.OracleSid, p.Inst.InstanceType, p.Inst.PMONpid, p.Inst.OracleHome, p.Inst.OSUser}
+	want := []interface{}{"ORCL", InstanceTypeASM, int32(200), "/u01/grid", "oracle"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("field %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -1,26 +1,77 @@
 package oracle
 
 import (
+	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/shirou/gopsutil/v3/process"
 )
 
 type PID int32
 
+// Instance types
+const (
+	InstanceTypeDatabase = "database"
+	InstanceTypeASM      = "asm"
+	InstanceTypeAPX      = "apx"
+)
+
+// FoundProcess is a process matching the discovery pattern
+type FoundProcess struct {
+	Proc *process.Process
+	SID  string // SID named group
+	Type string // database, asm or apx (TYPE named group or SID prefix)
+	Home string // HOME named group
+	User string // USER named group or process owner
+	// all named groups in the pattern
+	Groups map[string]string
+}
+
+// ID returns the process identity, SID or SID@USER for SIDs found more than once
+func (fp *FoundProcess) ID(duplicated bool) string {
+	if duplicated && len(fp.User) > 0 {
+		return fp.SID + "@" + fp.User
+	}
+	return fp.SID
+}
+
+// instanceType classifies the instance by the TYPE group (ora_pmon, asm_pmon, apx_pmon
+// process prefixes) or by the SID (+ASM, +APX)
+func instanceType(typ string, sid string) string {
+	switch strings.ToLower(typ) {
+	case "asm":
+		return InstanceTypeASM
+	case "apx":
+		return InstanceTypeAPX
+	case "":
+		switch {
+		case strings.HasPrefix(sid, "+ASM"):
+			return InstanceTypeASM
+		case strings.HasPrefix(sid, "+APX"):
+			return InstanceTypeAPX
+		}
+	}
+	return InstanceTypeDatabase
+}
+
 // ProcessFinder uses gopsutil to find processes
 type ProcessFinder struct{}
 
-// FullPattern matches on the command line when the process was executed
-func (pg *ProcessFinder) FullPattern(pattern string) (map[string]*process.Process, error) {
-	pids := make(map[string]*process.Process)
+// FullPattern matches on the command line when the process was executed, the
+// pattern should have the SID named group and can have TYPE, HOME and USER groups
+func (pg *ProcessFinder) FullPattern(pattern string) ([]*FoundProcess, error) {
+	found := []*FoundProcess{}
 	regxPattern, err := regexp.Compile(pattern)
 	if err != nil {
-		return pids, err
+		return found, err
+	}
+	if regxPattern.SubexpIndex("SID") < 0 {
+		return found, fmt.Errorf("pattern %s has no SID named group", pattern)
 	}
 	procs, err := pg.FastProcessList()
 	if err != nil {
-		return pids, err
+		return found, err
 	}
 	for _, p := range procs {
 		cmd, err := p.Cmdline()
@@ -29,20 +80,34 @@ func (pg *ProcessFinder) FullPattern(pattern string) (map[string]*process.Proces
 			// or you having no permissions to access it
 			continue
 		}
-		if regxPattern.MatchString(cmd) {
-			match := regxPattern.FindStringSubmatch(cmd)
-			result := make(map[string]string)
-			for i, name := range regxPattern.SubexpNames() {
-				if i != 0 && name != "" {
-					result[name] = match[i]
-				}
-			}
-			if len(result) == 1 {
-				pids[result["SID"]] = p
+		match := regxPattern.FindStringSubmatch(cmd)
+		if match == nil {
+			continue
+		}
+		result := make(map[string]string)
+		for i, name := range regxPattern.SubexpNames() {
+			if i != 0 && name != "" {
+				result[name] = match[i]
 			}
 		}
+		if len(result["SID"]) == 0 {
+			continue
+		}
+		fp := &FoundProcess{
+			Proc:   p,
+			SID:    result["SID"],
+			Type:   instanceType(result["TYPE"], result["SID"]),
+			Home:   result["HOME"],
+			User:   result["USER"],
+			Groups: result,
+		}
+		if len(fp.User) == 0 {
+			// process owner
+			fp.User, _ = p.Username()
+		}
+		found = append(found, fp)
 	}
-	return pids, err
+	return found, nil
 }
 
 func (pg *ProcessFinder) FastProcessList() ([]*process.Process, error) {
@@ -0,0 +1,44 @@
+package oracle
+
+import "testing"
+
+func TestFoundProcessID(t *testing.T) {
+	tests := []struct {
+		sid        string
+		user       string
+		duplicated bool
+		want       string
+	}{
+		{"ORCL", "oracle", false, "ORCL"},
+		{"+ASM1", "grid", false, "+ASM1"},
+		{"ORCL", "oracle", true, "ORCL@oracle"},
+		{"ORCL", "grid", true, "ORCL@grid"},
+		{"ORCL", "", true, "ORCL"},
+	}
+	for _, tt := range tests {
+		fp := &FoundProcess{SID: tt.sid, User: tt.user}
+		if got := fp.ID(tt.duplicated); got != tt.want {
+			t.Errorf("ID(%s,%s,%t): got %s, want %s", tt.sid, tt.user, tt.duplicated, got, tt.want)
+		}
+	}
+}
+
+func TestInstanceType(t *testing.T) {
+	tests := []struct {
+		typ  string
+		sid  string
+		want string
+	}{
+		{"ora", "ORCL", InstanceTypeDatabase},
+		{"", "ORCL", InstanceTypeDatabase},
+		{"asm", "+ASM1", InstanceTypeASM},
+		{"", "+ASM1", InstanceTypeASM},
+		{"APX", "+APX1", InstanceTypeAPX},
+		{"", "+APX1", InstanceTypeAPX},
+	}
+	for _, tt := range tests {
+		if got := instanceType(tt.typ, tt.sid); got != tt.want {
+			t.Errorf("instanceType(%s,%s): got %s, want %s", tt.typ, tt.sid, got, tt.want)
+		}
+	}
+}
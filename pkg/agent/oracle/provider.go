@@ -0,0 +1,127 @@
+package oracle
+
+import (
+	"sync"
+
+	"github.com/toni-moreno/oracle_collector/pkg/config"
+)
+
+// DiscoveredTarget is a candidate instance found by a discovery provider
+type DiscoveredTarget struct {
+	ID           string               // stable identity: SID (or SID@USER) for local instances, target name for remote ones
+	SID          string               // instance SID (local instances)
+	InstanceType string               // database (default), asm or apx
+	PMONpid      int32                // PMON process pid (local instances)
+	OracleHome   string               // ORACLE_HOME (local instances)
+	OSUser       string               // PMON process owner (local instances)
+	Target       *config.TargetConfig // DSN, credentials and labels (remote targets), nil for local instances
+}
+
+// DiscoveryProvider finds the instances to monitor
+type DiscoveryProvider interface {
+	// Name identifies the provider in logs and discover_stats
+	Name() string
+	// Enabled checks if the provider is configured
+	Enabled(cfg *config.DiscoveryConfig) bool
+	// Discover returns the current targets, providers can return its last
+	// valid targets with the error
+	Discover(cfg *config.DiscoveryConfig) ([]*DiscoveredTarget, error)
+}
+
+var (
+	providersMutex sync.Mutex
+	providers      []DiscoveryProvider
+)
+
+// RegisterProvider adds a discovery provider, on duplicated identities the
+// targets of the first registered providers are used
+func RegisterProvider(p DiscoveryProvider) {
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+	providers = append(providers, p)
+}
+
+// GetProviders returns all registered discovery providers
+func GetProviders() []DiscoveryProvider {
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+	return providers
+}
+
+func (dt *DiscoveredTarget) newInstance() *OracleInstance {
+	oi := &OracleInstance{
+		DiscoveredSid: dt.ID,
+		OracleSid:     dt.SID,
+		InstanceType:  dt.InstanceType,
+		PMONpid:       dt.PMONpid,
+		OracleHome:    dt.OracleHome,
+		OSUser:        dt.OSUser,
+		Target:        dt.Target,
+	}
+	if len(oi.OracleSid) == 0 {
+		oi.OracleSid = dt.ID
+	}
+	if len(oi.InstanceType) == 0 {
+		oi.InstanceType = InstanceTypeDatabase
+	}
+	return oi
+}
+
+// pmonProvider scans the system processes with the oracle_discovery_sid_regex
+type pmonProvider struct{}
+
+func (pp *pmonProvider) Name() string {
+	return "pmon"
+}
+
+func (pp *pmonProvider) Enabled(cfg *config.DiscoveryConfig) bool {
+	return true
+}
+
+func (pp *pmonProvider) Discover(cfg *config.DiscoveryConfig) ([]*DiscoveredTarget, error) {
+	targets := []*DiscoveredTarget{}
+	pf := ProcessFinder{}
+	pmonfound, err := pf.FullPattern(cfg.OracleDiscoverySidRegex)
+	// same SID running with different users (homes)
+	sids := make(map[string]int)
+	for _, p := range pmonfound {
+		sids[p.SID]++
+	}
+	for _, p := range pmonfound {
+		targets = append(targets, &DiscoveredTarget{
+			ID:           p.ID(sids[p.SID] > 1),
+			SID:          p.SID,
+			InstanceType: p.Type,
+			PMONpid:      p.Proc.Pid,
+			OracleHome:   p.Home,
+			OSUser:       p.User,
+		})
+	}
+	return targets, err
+}
+
+// staticProvider returns the [[oracle-discovery.static-target]] targets
+type staticProvider struct{}
+
+func (sp *staticProvider) Name() string {
+	return "static"
+}
+
+func (sp *staticProvider) Enabled(cfg *config.DiscoveryConfig) bool {
+	return len(cfg.StaticTargets) > 0
+}
+
+func (sp *staticProvider) Discover(cfg *config.DiscoveryConfig) ([]*DiscoveredTarget, error) {
+	targets := []*DiscoveredTarget{}
+	for _, t := range cfg.StaticTargets {
+		targets = append(targets, &DiscoveredTarget{ID: t.Name, Target: t})
+	}
+	return targets, nil
+}
+
+func init() {
+	RegisterProvider(&pmonProvider{})
+	RegisterProvider(&staticProvider{})
+	RegisterProvider(&fileProvider{targets: make(map[string][]*config.TargetConfig)})
+	RegisterProvider(&httpSDProvider{})
+}
@@ -0,0 +1,152 @@
+package oracle
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/toni-moreno/oracle_collector/pkg/config"
+)
+
+// RequestData is the data available in templated requests
+type RequestData struct {
+	ViewPrefix string            // GV$ with clusterware enabled, V$ otherwise
+	Params     map[string]string // metric params
+	Labels     map[string]string // instance labels
+}
+
+// qQuoteEnd returns the closing delimiter of q'<delim>...<delim>' literals
+func qQuoteEnd(d rune) rune {
+	switch d {
+	case '[':
+		return ']'
+	case '{':
+		return '}'
+	case '<':
+		return '>'
+	case '(':
+		return ')'
+	}
+	return d
+}
+
+func isIdentChar(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '$' || c == '#'
+}
+
+// isQQuote checks if there is a q'<delim> (or nq') literal start at i
+func isQQuote(r []rune, i int) bool {
+	if (r[i] != 'q' && r[i] != 'Q') || i+2 >= len(r) || r[i+1] != '\'' {
+		return false
+	}
+	if i > 0 && (r[i-1] == 'n' || r[i-1] == 'N') {
+		i--
+	}
+	return i == 0 || !isIdentChar(r[i-1])
+}
+
+// bindNames returns the :name bind variables in the query, quoted strings
+// ('...' and q'[...]' literals), quoted identifiers and comments are skipped
+func bindNames(query string) []string {
+	names := []string{}
+	found := make(map[string]bool)
+	r := []rune(query)
+	for i := 0; i < len(r); i++ {
+		switch {
+		case isQQuote(r, i):
+			end := qQuoteEnd(r[i+2])
+			for i += 3; i+1 < len(r) && !(r[i] == end && r[i+1] == '\''); i++ {
+			}
+			i++
+		case r[i] == '\'' || r[i] == '"':
+			// '' inside strings is also skipped as an empty string
+			end := r[i]
+			for i++; i < len(r) && r[i] != end; i++ {
+			}
+		case r[i] == '-' && i+1 < len(r) && r[i+1] == '-':
+			for ; i < len(r) && r[i] != '\n'; i++ {
+			}
+		case r[i] == '/' && i+1 < len(r) && r[i+1] == '*':
+			for i += 2; i+1 < len(r) && !(r[i] == '*' && r[i+1] == '/'); i++ {
+			}
+			i++
+		case r[i] == ':' && i+1 < len(r) && unicode.IsLetter(r[i+1]):
+			j := i + 1
+			for ; j < len(r) && isIdentChar(r[j]); j++ {
+			}
+			name := string(r[i+1 : j])
+			if !found[strings.ToUpper(name)] {
+				found[strings.ToUpper(name)] = true
+				names = append(names, name)
+			}
+			i = j - 1
+		}
+	}
+	return names
+}
+
+// lookupParam finds the param by name (bind names are case insensitive)
+func lookupParam(params map[string]string, name string) (string, bool) {
+	if v, ok := params[name]; ok {
+		return v, true
+	}
+	for k, v := range params {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// GetParams returns the metric params for this instance: dynamic-params rule
+// params and instance labels (only for already defined params) override the
+// metric ones
+func (oi *OracleInstance) GetParams(params map[string]string) map[string]string {
+	oi.Lock()
+	defer oi.Unlock()
+	ret := make(map[string]string, len(params)+len(oi.params))
+	for k, v := range params {
+		ret[k] = v
+	}
+	for k, v := range oi.params {
+		ret[k] = v
+	}
+	for k := range ret {
+		if v, ok := oi.labels[k]; ok {
+			ret[k] = v
+		}
+	}
+	return ret
+}
+
+// PrepareRequest renders the templated request (parsed on the metric config
+// validation) and returns the named binds for the :name variables found in the query
+func (oi *OracleInstance) PrepareRequest(mc *config.OracleMetricConfig, params map[string]string) (string, []interface{}, error) {
+	p := oi.GetParams(params)
+	query := mc.Request
+	if mc.Template != nil {
+		oi.Lock()
+		d := RequestData{ViewPrefix: "V$", Params: p, Labels: oi.labels}
+		if oi.ClusteWareEnabled {
+			d.ViewPrefix = "GV$"
+		}
+		var b bytes.Buffer
+		err := mc.Template.Execute(&b, d)
+		oi.Unlock()
+		if err != nil {
+			return "", nil, fmt.Errorf("Error on request template: %s", err)
+		}
+		query = b.String()
+	}
+	args := []interface{}{}
+	for _, name := range bindNames(query) {
+		v, ok := lookupParam(p, name)
+		if !ok {
+			return "", nil, fmt.Errorf("Error on request binds: no param for bind variable :%s", name)
+		}
+		args = append(args, sql.Named(name, v))
+	}
+	return query, args, nil
+}
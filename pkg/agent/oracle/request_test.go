@@ -0,0 +1,110 @@
+package oracle
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/toni-moreno/oracle_collector/pkg/config"
+)
+
+func TestBindNames(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"select 1 from dual", []string{}},
+		{"select * from t where a = :a and b = :B and c = :A", []string{"a", "B"}},
+		{"select ':x', \":y\" from t where a = :a_1", []string{"a_1"}},
+		{"select 1 from t -- :x\nwhere a = :a /* :y */", []string{"a"}},
+		{"select to_char(d, 'HH24:MI:SS') from t where a = :a", []string{"a"}},
+		{"select q'[it's :x]' from t where a = :a", []string{"a"}},
+		{"select q'{:x}', Q'<:y>', q'(:z)', nq'!it's :w!' from t where a = :a", []string{"a"}},
+		{"select q'[a]]:x]' from t where a = :a", []string{"a"}},
+		{"select freq from t where a = :a", []string{"a"}},
+		{"select seq':a' from t", []string{}},
+		{"select 1 from t where a = :1", []string{}},
+	}
+	for _, tt := range tests {
+		if got := bindNames(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("bindNames(%q) got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestPrepareRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		request   string
+		params    map[string]string
+		clusterd  bool
+		wantQuery string
+		wantArgs  []interface{}
+		wantErr   bool
+	}{
+		{
+			name:      "plain",
+			request:   "select 1 value from dual",
+			wantQuery: "select 1 value from dual",
+			wantArgs:  []interface{}{},
+		},
+		{
+			name:      "binds",
+			request:   "select count(*) value from v$session where username = :user",
+			params:    map[string]string{"USER": "SYS"},
+			wantQuery: "select count(*) value from v$session where username = :user",
+			wantArgs:  []interface{}{sql.Named("user", "SYS")},
+		},
+		{
+			name:    "missing bind",
+			request: "select count(*) value from v$session where username = :user",
+			wantErr: true,
+		},
+		{
+			name:      "template",
+			request:   "select count(*) value from {{ .ViewPrefix }}{{ .Params.view }}",
+			params:    map[string]string{"view": "SESSION"},
+			wantQuery: "select count(*) value from V$SESSION",
+			wantArgs:  []interface{}{},
+		},
+		{
+			name:      "template with clusterware",
+			request:   "select count(*) value from {{ .ViewPrefix }}SESSION where inst_id = {{ .Labels.inst }}",
+			clusterd:  true,
+			wantQuery: "select count(*) value from GV$SESSION where inst_id = 1",
+			wantArgs:  []interface{}{},
+		},
+		{
+			name:    "template missing param",
+			request: "select count(*) value from {{ .Params.view }}",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		mc := &config.OracleMetricConfig{
+			ID:          tt.name,
+			Context:     tt.name,
+			Request:     tt.request,
+			MetricsType: map[string]string{"value": "INTEGER"},
+			Params:      tt.params,
+		}
+		if err := mc.Validate(); err != nil {
+			t.Fatalf("%s: validate error: %s", tt.name, err)
+		}
+		oi := &OracleInstance{DiscoveredSid: "TEST", ClusteWareEnabled: tt.clusterd, labels: map[string]string{"inst": "1"}}
+		query, args, err := oi.PrepareRequest(mc, mc.Params)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error %t", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if query != tt.wantQuery {
+			t.Errorf("%s: got query %q, want %q", tt.name, query, tt.wantQuery)
+		}
+		if !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("%s: got args %v, want %v", tt.name, args, tt.wantArgs)
+		}
+	}
+}
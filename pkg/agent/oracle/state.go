@@ -0,0 +1,193 @@
+package oracle
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// InstanceState is the health state of a monitored instance
+type InstanceState string
+
+const (
+	StateConnecting InstanceState = "CONNECTING" // connecting for the first time
+	StateUp         InstanceState = "UP"         // open and last queries ok
+	StateDegraded   InstanceState = "DEGRADED"   // open but last query failed
+	StateDown       InstanceState = "DOWN"       // connection lost, reconnecting
+	StateMounted    InstanceState = "MOUNTED"    // instance mounted, database not open
+	StateStarted    InstanceState = "STARTED"    // instance started (nomount)
+)
+
+// reconnection backoff limits
+const (
+	reconnectMinBackoff = 1 * time.Second
+	reconnectMaxBackoff = 2 * time.Minute
+)
+
+// degradedTimeouts is the number of consecutive query timeouts to set the
+// instance DEGRADED
+const degradedTimeouts = 3
+
+// ORA errors for lost connections or unreachable instances
+var connErrorRegex = regexp.MustCompile(`ORA-(03113|03114|03135|01012|01033|01034|01089|01092|12514|12518|12528|12537|12541|12543|12547|12570|28547)\b|DPI-1010|DPI-1080|connection (closed|refused|reset)|broken pipe`)
+
+// IsConnectionError checks if the error is a lost connection one
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, driver.ErrBadConn) || connErrorRegex.MatchString(err.Error())
+}
+
+// ORA-01013: user requested cancel of current operation (context timeout)
+var timeoutErrorRegex = regexp.MustCompile(`ORA-01013\b`)
+
+// IsTimeoutError checks if the query was cancelled by its timeout
+func IsTimeoutError(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, context.DeadlineExceeded) || timeoutErrorRegex.MatchString(err.Error())
+}
+
+// stateFromStatus returns the state for the v$instance STATUS
+func stateFromStatus(status string) InstanceState {
+	switch status {
+	case "MOUNTED":
+		return StateMounted
+	case "STARTED":
+		return StateStarted
+	}
+	return StateUp
+}
+
+// setState changes the instance state, stateMutex should be held
+func (oi *OracleInstance) setState(s InstanceState, reason error) {
+	if oi.state == s {
+		return
+	}
+	if reason != nil {
+		log.Warnf("[DISCOVERY] Instance %s state changed %s => %s: %s", oi.DiscoveredSid, oi.state, s, reason)
+	} else {
+		log.Infof("[DISCOVERY] Instance %s state changed %s => %s", oi.DiscoveredSid, oi.state, s)
+	}
+	oi.state = s
+	oi.stateSince = time.Now()
+	if s == StateDown && !oi.reconnecting {
+		oi.reconnecting = true
+		go oi.reconnect()
+	}
+}
+
+// GetState returns the instance state and the time when it was set
+func (oi *OracleInstance) GetState() (InstanceState, time.Time) {
+	oi.stateMutex.Lock()
+	defer oi.stateMutex.Unlock()
+	return oi.state, oi.stateSince
+}
+
+// GetReconnects returns the number of successful reconnections
+func (oi *OracleInstance) GetReconnects() int {
+	oi.stateMutex.Lock()
+	defer oi.stateMutex.Unlock()
+	return oi.reconnects
+}
+
+// IsQueryable checks if metric groups can query the instance
+func (oi *OracleInstance) IsQueryable() bool {
+	s, _ := oi.GetState()
+	return s != StateDown && s != StateConnecting
+}
+
+// queryResult updates the state after a query on the instance, only lost
+// connections and consecutive timeouts change it: other SQL errors are
+// reported by each metric (collect_stats)
+func (oi *OracleInstance) queryResult(err error) {
+	oi.stateMutex.Lock()
+	defer oi.stateMutex.Unlock()
+	switch {
+	case oi.state == StateConnecting:
+		// Init errors are handled by the discovery process
+	case err == nil:
+		oi.timeouts = 0
+		if oi.state == StateDegraded {
+			oi.setState(stateFromStatus(oi.instStatus), nil)
+		}
+	case IsConnectionError(err):
+		oi.setState(StateDown, err)
+	case IsTimeoutError(err):
+		oi.timeouts++
+		if oi.state == StateUp && oi.timeouts >= degradedTimeouts {
+			oi.setState(StateDegraded, fmt.Errorf("%d consecutive query timeouts, last: %s", oi.timeouts, err))
+		}
+	}
+}
+
+// infoResult updates the state after getting the instance info
+func (oi *OracleInstance) infoResult(status string, err error) {
+	oi.stateMutex.Lock()
+	defer oi.stateMutex.Unlock()
+	if err != nil {
+		if oi.state == StateConnecting {
+			// Init errors are handled by the discovery process
+			return
+		}
+		if IsConnectionError(err) {
+			oi.setState(StateDown, err)
+		} else if oi.state != StateDown {
+			oi.setState(StateDegraded, err)
+		}
+		return
+	}
+	oi.instStatus = status
+	oi.setState(stateFromStatus(status), nil)
+}
+
+// reconnect opens new connections to the instance with exponential backoff
+// until the instance is not DOWN or it is removed
+func (oi *OracleInstance) reconnect() {
+	backoff := reconnectMinBackoff
+	attempt := 0
+	for {
+		select {
+		case <-time.After(backoff):
+		case <-oi.done:
+			return
+		}
+		oi.stateMutex.Lock()
+		if oi.state != StateDown {
+			oi.reconnecting = false
+			oi.stateMutex.Unlock()
+			return
+		}
+		oi.stateMutex.Unlock()
+		attempt++
+		oi.log.Infof("Reconnecting instance %s (attempt %d)", oi.DiscoveredSid, attempt)
+		err := oi.connect()
+		if err == nil {
+			err = oi.UpdateInfo()
+		}
+		if err == nil {
+			log.Infof("[DISCOVERY] Instance %s reconnected after %d attempts", oi.DiscoveredSid, attempt)
+			oi.stateMutex.Lock()
+			oi.reconnects++
+			// could be lost again while getting the instance info
+			if oi.state != StateDown {
+				oi.reconnecting = false
+				oi.stateMutex.Unlock()
+				return
+			}
+			oi.stateMutex.Unlock()
+			backoff = reconnectMinBackoff
+			continue
+		}
+		backoff *= 2
+		if backoff > reconnectMaxBackoff {
+			backoff = reconnectMaxBackoff
+		}
+		log.Warnf("[DISCOVERY] Error on reconnecting instance %s (attempt %d, next in %s): %s", oi.DiscoveredSid, attempt, backoff, err)
+	}
+}
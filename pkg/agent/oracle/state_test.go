@@ -0,0 +1,87 @@
+package oracle
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/toni-moreno/oracle_collector/pkg/agent/data"
+)
+
+func TestQueryResultState(t *testing.T) {
+	sqlErr := errors.New("ORA-00942: table or view does not exist")
+	timeoutErr := fmt.Errorf("query: %w", context.DeadlineExceeded)
+	cancelErr := errors.New("ORA-01013: user requested cancel of current operation")
+	connErr := errors.New("ORA-03113: end-of-file on communication channel")
+	tests := []struct {
+		name    string
+		results []error
+		want    InstanceState
+	}{
+		{"ok", []error{nil, nil}, StateUp},
+		{"sql errors do not degrade", []error{sqlErr, sqlErr, sqlErr, sqlErr}, StateUp},
+		{"few timeouts", []error{timeoutErr, cancelErr}, StateUp},
+		{"consecutive timeouts", []error{timeoutErr, cancelErr, timeoutErr}, StateDegraded},
+		{"timeouts with sql errors", []error{timeoutErr, sqlErr, cancelErr, timeoutErr}, StateDegraded},
+		{"timeouts reset on success", []error{timeoutErr, timeoutErr, nil, timeoutErr}, StateUp},
+		{"degraded back to up", []error{timeoutErr, timeoutErr, timeoutErr, nil}, StateUp},
+		{"connection lost", []error{sqlErr, connErr}, StateDown},
+	}
+	for _, tt := range tests {
+		// reconnecting avoids starting the reconnection process on DOWN
+		oi := &OracleInstance{DiscoveredSid: "TEST", state: StateUp, instStatus: "OPEN", reconnecting: true}
+		for _, err := range tt.results {
+			oi.queryResult(err)
+		}
+		if s, _ := oi.GetState(); s != tt.want {
+			t.Errorf("%s: got state %s, want %s", tt.name, s, tt.want)
+		}
+	}
+}
+
+// errQueryer fails the queries as the driver does: waiting for the context
+// on timeouts or returning the error
+type errQueryer struct {
+	err error
+}
+
+func (q errQueryer) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if q.err == nil {
+		<-ctx.Done()
+		return nil, errors.New("ORA-01013: user requested cancel of current operation")
+	}
+	return nil, q.err
+}
+
+func TestQueryTableErrorState(t *testing.T) {
+	sqlErr := errors.New("ORA-00942: table or view does not exist")
+	tests := []struct {
+		name string
+		q    errQueryer
+		want InstanceState
+	}{
+		{"timeouts", errQueryer{}, StateDegraded},
+		{"cancelled", errQueryer{err: errors.New("ORA-01013: user requested cancel of current operation")}, StateDegraded},
+		{"bad connection", errQueryer{err: driver.ErrBadConn}, StateDown},
+		{"sql error", errQueryer{err: sqlErr}, StateUp},
+	}
+	for _, tt := range tests {
+		oi := &OracleInstance{DiscoveredSid: "TEST", state: StateUp, instStatus: "OPEN", reconnecting: true}
+		for i := 0; i < degradedTimeouts; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+			_, err := queryTable(ctx, tt.q, "select 1 from dual", nil, data.NewDatatable(nil))
+			cancel()
+			if err == nil {
+				t.Fatalf("%s: expected query error", tt.name)
+			}
+			oi.queryResult(err)
+		}
+		if s, _ := oi.GetState(); s != tt.want {
+			t.Errorf("%s: got state %s, want %s", tt.name, s, tt.want)
+		}
+	}
+}
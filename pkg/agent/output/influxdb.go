@@ -0,0 +1,179 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/serializers"
+	"github.com/toni-moreno/oracle_collector/pkg/config"
+)
+
+// PermanentError is returned when the batch will never be accepted by
+// the destination and retrying is useless.
+type PermanentError struct {
+	Err error
+}
+
+func (pe *PermanentError) Error() string {
+	return pe.Err.Error()
+}
+
+// InfluxOutput writes metrics to the InfluxDB v1 (/write) or v2 (/api/v2/write) HTTP API
+type InfluxOutput struct {
+	cfg      *config.OutputSinkConfig
+	stats    *sinkCounters
+	client   *http.Client
+	writeURL string
+	ser      serializers.Serializer
+	chExit   <-chan bool
+}
+
+// NewInfluxOutput creates the InfluxDB output
+func NewInfluxOutput(cfg *config.OutputSinkConfig, stats *sinkCounters) *InfluxOutput {
+	return &InfluxOutput{
+		cfg:   cfg,
+		stats: stats,
+	}
+}
+
+func (ifx *InfluxOutput) SetSerializer(ser serializers.Serializer) {
+	ifx.ser = ser
+}
+
+// SetExit sets the sink exit channel, pending retries are cancelled when closed
+func (ifx *InfluxOutput) SetExit(chExit <-chan bool) {
+	ifx.chExit = chExit
+}
+
+func (ifx *InfluxOutput) Connect() error {
+	params := url.Values{}
+	var path string
+	if ifx.cfg.Type == "influxdb_v2" {
+		path = "/api/v2/write"
+		params.Set("org", ifx.cfg.Organization)
+		params.Set("bucket", ifx.cfg.Bucket)
+	} else {
+		path = "/write"
+		params.Set("db", ifx.cfg.Database)
+		if len(ifx.cfg.RetentionPolicy) > 0 {
+			params.Set("rp", ifx.cfg.RetentionPolicy)
+		}
+	}
+	params.Set("precision", "ns")
+	ifx.writeURL = strings.TrimSuffix(ifx.cfg.URL, "/") + path + "?" + params.Encode()
+	ifx.client = &http.Client{Timeout: ifx.cfg.Timeout}
+	return nil
+}
+
+func (ifx *InfluxOutput) post(body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, ifx.writeURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	switch {
+	case len(ifx.cfg.Token) > 0:
+		req.Header.Set("Authorization", "Token "+ifx.cfg.Token)
+	case len(ifx.cfg.Username) > 0:
+		req.SetBasicAuth(ifx.cfg.Username, ifx.cfg.Password)
+	}
+	resp, err := ifx.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return resp.StatusCode, nil
+	}
+	msg, _ := ioutil.ReadAll(resp.Body)
+	err = fmt.Errorf("HTTP status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	// client errors will fail again (except throttling or timeouts)
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusRequestTimeout {
+		return resp.StatusCode, &PermanentError{Err: err}
+	}
+	return resp.StatusCode, err
+}
+
+// wait returns false if the sink has been stopped while waiting
+func (ifx *InfluxOutput) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ifx.chExit:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Write sends the batch, batches too large for the server (HTTP 413) are
+// split in halves until accepted (only a single metric too large is dropped)
+func (ifx *InfluxOutput) Write(metrics []telegraf.Metric) error {
+	status, err := ifx.writeBatch(metrics)
+	if status != http.StatusRequestEntityTooLarge || len(metrics) < 2 {
+		return err
+	}
+	half := len(metrics) / 2
+	log.Warnf("[OUTPUT] Sink [%s] Batch of %d metrics too large, splitting in %d and %d: %s", ifx.cfg.Name, len(metrics), half, len(metrics)-half, err)
+	// influx writes are idempotent: the batch can be sent again if the second
+	// half fails, and the rest is sent even with permanent errors on the first half
+	errFirst := ifx.Write(metrics[:half])
+	if _, ok := errFirst.(*PermanentError); errFirst != nil && !ok {
+		return errFirst
+	}
+	if err := ifx.Write(metrics[half:]); err != nil {
+		return err
+	}
+	return errFirst
+}
+
+// writeBatch sends the batch retrying with exponential backoff on errors, retries
+// are cancelled when the sink stops (metrics will remain in the buffer/spool)
+func (ifx *InfluxOutput) writeBatch(metrics []telegraf.Metric) (int, error) {
+	body, err := ifx.ser.SerializeBatch(metrics)
+	if err != nil {
+		return 0, &PermanentError{Err: err}
+	}
+	start := time.Now()
+	maxRetries := *ifx.cfg.MaxRetries
+	wait := ifx.cfg.RetryInterval
+	retries := 0
+	var status int
+	for {
+		status, err = ifx.post(body)
+		if err == nil {
+			break
+		}
+		if _, ok := err.(*PermanentError); ok || retries >= maxRetries {
+			break
+		}
+		log.Warnf("[OUTPUT] Sink [%s] Error on write (retry %d/%d in %s): %s", ifx.cfg.Name, retries+1, maxRetries, wait, err)
+		if !ifx.wait(wait) {
+			log.Warnf("[OUTPUT] Sink [%s] Retries cancelled on exit", ifx.cfg.Name)
+			break
+		}
+		retries++
+		wait *= 2
+		if wait > ifx.cfg.RetryMaxInterval {
+			wait = ifx.cfg.RetryMaxInterval
+		}
+	}
+	ifx.stats.addWrite(len(body), status, retries, time.Since(start), err)
+	return status, err
+}
+
+func (ifx *InfluxOutput) Close() error {
+	if ifx.client != nil {
+		ifx.client.CloseIdleConnections()
+	}
+	return nil
+}
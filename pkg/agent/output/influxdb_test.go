@@ -0,0 +1,266 @@
+package output
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/sirupsen/logrus"
+	"github.com/toni-moreno/oracle_collector/pkg/config"
+)
+
+func init() {
+	l := logrus.New()
+	l.SetOutput(ioutil.Discard)
+	SetLogger(l)
+}
+
+// influxServer records the requests and answers with the given status codes
+// (the last one is repeated)
+type influxServer struct {
+	sync.Mutex
+	status []int
+	reqs   []*http.Request
+	bodies []string
+}
+
+func (is *influxServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	is.Lock()
+	defer is.Unlock()
+	is.reqs = append(is.reqs, r)
+	is.bodies = append(is.bodies, string(body))
+	st := is.status[0]
+	if len(is.status) > 1 {
+		is.status = is.status[1:]
+	}
+	w.WriteHeader(st)
+}
+
+func testMetrics(n int) []telegraf.Metric {
+	ret := []telegraf.Metric{}
+	for i := 0; i < n; i++ {
+		ret = append(ret, metric.New("oracle_test", map[string]string{"instance": "ORCL"}, map[string]interface{}{"value": i}, time.Unix(0, 0)))
+	}
+	return ret
+}
+
+func newTestInfluxSink(t *testing.T, url string, typ string, retries int, batch int) *Sink {
+	cfg := &config.OutputSinkConfig{
+		Name:          "test",
+		Type:          typ,
+		URL:           url,
+		Database:      "oracle",
+		Organization:  "org",
+		Bucket:        "bucket",
+		FlushPeriod:   time.Second,
+		BufferSize:    100,
+		BatchSize:     batch,
+		MaxRetries:    &retries,
+		RetryInterval: time.Millisecond,
+	}
+	if typ == "influxdb_v2" {
+		cfg.Token = "token"
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected config error: %s", err)
+	}
+	s, err := NewSink(cfg)
+	if err != nil {
+		t.Fatalf("unexpected sink error: %s", err)
+	}
+	if err := s.output.Connect(); err != nil {
+		t.Fatalf("unexpected connect error: %s", err)
+	}
+	return s
+}
+
+func TestInfluxOutputEndpoints(t *testing.T) {
+	tests := []struct {
+		typ    string
+		path   string
+		query  map[string]string
+		header string
+	}{
+		{"influxdb", "/write", map[string]string{"db": "oracle", "precision": "ns"}, ""},
+		{"influxdb_v2", "/api/v2/write", map[string]string{"org": "org", "bucket": "bucket", "precision": "ns"}, "Token token"},
+	}
+	for _, tt := range tests {
+		is := &influxServer{status: []int{http.StatusNoContent}}
+		ts := httptest.NewServer(is)
+		s := newTestInfluxSink(t, ts.URL, tt.typ, 0, 0)
+		if err := s.output.Write(testMetrics(1)); err != nil {
+			t.Errorf("%s: unexpected write error: %s", tt.typ, err)
+		}
+		ts.Close()
+		if len(is.reqs) != 1 {
+			t.Fatalf("%s: got %d requests, want 1", tt.typ, len(is.reqs))
+		}
+		r := is.reqs[0]
+		if r.URL.Path != tt.path {
+			t.Errorf("%s: got path %s, want %s", tt.typ, r.URL.Path, tt.path)
+		}
+		for k, v := range tt.query {
+			if got := r.URL.Query().Get(k); got != v {
+				t.Errorf("%s: got %s=%s, want %s", tt.typ, k, got, v)
+			}
+		}
+		if got := r.Header.Get("Authorization"); got != tt.header {
+			t.Errorf("%s: got Authorization [%s], want [%s]", tt.typ, got, tt.header)
+		}
+		if !strings.HasPrefix(is.bodies[0], "oracle_test,instance=ORCL value=0i 0") {
+			t.Errorf("%s: unexpected body %q", tt.typ, is.bodies[0])
+		}
+	}
+}
+
+func TestInfluxOutputRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    []int
+		retries   int
+		permanent bool
+		fail      bool
+		requests  int
+	}{
+		{"ok", []int{204}, 3, false, false, 1},
+		{"4xx is permanent", []int{400, 204}, 3, true, true, 1},
+		{"5xx is retried", []int{500, 503, 204}, 3, false, false, 3},
+		{"429 is retried", []int{429, 204}, 3, false, false, 2},
+		{"5xx retries exhausted", []int{500}, 2, false, true, 3},
+		{"no retries", []int{500, 204}, 0, false, true, 1},
+	}
+	for _, tt := range tests {
+		is := &influxServer{status: tt.status}
+		ts := httptest.NewServer(is)
+		s := newTestInfluxSink(t, ts.URL, "influxdb", tt.retries, 0)
+		err := s.output.Write(testMetrics(1))
+		ts.Close()
+		if (err != nil) != tt.fail {
+			t.Errorf("%s: got error %v, want error %t", tt.name, err, tt.fail)
+		}
+		if _, ok := err.(*PermanentError); ok != tt.permanent {
+			t.Errorf("%s: got permanent error %t, want %t", tt.name, ok, tt.permanent)
+		}
+		if len(is.reqs) != tt.requests {
+			t.Errorf("%s: got %d requests, want %d", tt.name, len(is.reqs), tt.requests)
+		}
+	}
+}
+
+// limitServer answers 413 to bodies with more than max lines
+type limitServer struct {
+	sync.Mutex
+	max      int
+	requests int
+	accepted []string
+}
+
+func (ls *limitServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	ls.Lock()
+	defer ls.Unlock()
+	ls.requests++
+	if len(lines) > ls.max {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	ls.accepted = append(ls.accepted, lines...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestInfluxOutputSplitTooLarge(t *testing.T) {
+	tests := []struct {
+		name      string
+		max       int
+		metrics   int
+		permanent bool
+		accepted  int
+		requests  int
+	}{
+		{"fits", 5, 5, false, 5, 1},
+		{"split in halves", 2, 5, false, 5, 5},
+		{"single metric too large", 0, 2, true, 0, 3},
+	}
+	for _, tt := range tests {
+		ls := &limitServer{max: tt.max}
+		ts := httptest.NewServer(ls)
+		s := newTestInfluxSink(t, ts.URL, "influxdb", 3, 0)
+		err := s.output.Write(testMetrics(tt.metrics))
+		ts.Close()
+		if _, ok := err.(*PermanentError); ok != tt.permanent || (err != nil && !ok) {
+			t.Errorf("%s: got error %v, want permanent error %t", tt.name, err, tt.permanent)
+		}
+		if len(ls.accepted) != tt.accepted {
+			t.Errorf("%s: got %d accepted metrics, want %d", tt.name, len(ls.accepted), tt.accepted)
+		}
+		if ls.requests != tt.requests {
+			t.Errorf("%s: got %d requests, want %d", tt.name, ls.requests, tt.requests)
+		}
+	}
+}
+
+func TestInfluxOutputRetriesCancelledOnStop(t *testing.T) {
+	is := &influxServer{status: []int{500}}
+	ts := httptest.NewServer(is)
+	defer ts.Close()
+	s := newTestInfluxSink(t, ts.URL, "influxdb", 3, 0)
+	s.cfg.RetryInterval = time.Hour
+	s.cfg.RetryMaxInterval = time.Hour
+	close(s.chExit)
+	done := make(chan error)
+	go func() { done <- s.output.Write(testMetrics(1)) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("expected error on cancelled write")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("write not cancelled on stop")
+	}
+}
+
+func TestSinkFlushBatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   []int
+		batch    int
+		requests int
+		written  int
+		dropped  int
+		buffered int
+	}{
+		{"single batch", []int{204}, 0, 1, 7, 0, 0},
+		{"split in batches", []int{204}, 3, 3, 7, 0, 0},
+		{"permanent error drops batch", []int{400, 204}, 3, 3, 4, 3, 0},
+		{"error keeps metrics", []int{204, 500}, 3, 2, 3, 0, 4},
+	}
+	for _, tt := range tests {
+		is := &influxServer{status: tt.status}
+		ts := httptest.NewServer(is)
+		s := newTestInfluxSink(t, ts.URL, "influxdb", 0, tt.batch)
+		s.Add(testMetrics(7))
+		n, _ := s.flushData()
+		ts.Close()
+		st := s.stats.Snapshot()
+		if len(is.reqs) != tt.requests {
+			t.Errorf("%s: got %d requests, want %d", tt.name, len(is.reqs), tt.requests)
+		}
+		if n != tt.written || int(st.MetricsWritten) != tt.written {
+			t.Errorf("%s: got %d/%d written, want %d", tt.name, n, st.MetricsWritten, tt.written)
+		}
+		if int(st.MetricsDropped) != tt.dropped {
+			t.Errorf("%s: got %d dropped, want %d", tt.name, st.MetricsDropped, tt.dropped)
+		}
+		if s.buffer.Len() != tt.buffered {
+			t.Errorf("%s: got %d buffered, want %d", tt.name, s.buffer.Len(), tt.buffered)
+		}
+	}
+}
@@ -1,25 +1,20 @@
 package output
 
 import (
-	"bufio"
-	"os"
-	"time"
+	"fmt"
+	"path/filepath"
+	"sync"
 
 	"github.com/influxdata/telegraf"
-	"github.com/influxdata/telegraf/models"
-	"github.com/influxdata/telegraf/plugins/serializers"
 	"github.com/sirupsen/logrus"
 	"github.com/toni-moreno/oracle_collector/pkg/config"
 )
 
 var (
-	log         *logrus.Logger
-	bufferSize  int
-	bachSize    int
-	flushPeriod time.Duration
-	chExit      chan bool
-	buffer      *models.Buffer
-	ser         serializers.Serializer
+	log     *logrus.Logger
+	dataDir string
+	sinks   []*Sink
+	wg      sync.WaitGroup
 )
 
 // SetLogger sets the current log output.
@@ -27,64 +22,101 @@ func SetLogger(l *logrus.Logger) {
 	log = l
 }
 
-func Init(cfg *config.OutputConfig) {
-	var err error
-	sc := &serializers.Config{TimestampUnits: 1 * time.Second}
-	sc.DataFormat = "influx"
-	ser, err = serializers.NewSerializer(sc)
-	if err != nil {
-		log.Error("[OUTPUT] Error in init serializer")
-	}
-	bufferSize = cfg.BufferSize
-	bachSize = cfg.BatchSize
-	flushPeriod = cfg.FlushPeriod
-	buffer = models.NewBuffer("oracle_collector", "buff", cfg.BufferSize)
-	chExit = make(chan bool)
-	go startOutputSender()
+// SetDataDir set the dir where the spool will be stored
+func SetDataDir(d string) {
+	dataDir = d
 }
 
-// End release Output process
-func End() {
-	close(chExit)
+// Output is the interface implemented by all sink destinations
+type Output interface {
+	// Connect initializes the output resources
+	Connect() error
+	// Write sends a batch of metrics to the destination
+	Write(metrics []telegraf.Metric) error
+	// Close releases all output resources
+	Close() error
 }
 
-func SendMetrics(metrics []telegraf.Metric) {
-	dropped := buffer.Add(metrics...)
-	if dropped > 0 {
-		log.Warnf("[OUTPUT]  Dropped metrics %d", dropped)
-	}
+// DirectOutput is implemented by outputs which should receive metrics as soon
+// as they are sent, without buffering (e.g. exporters keeping last values).
+type DirectOutput interface {
+	Output
+	Direct() bool
 }
 
-func flushData() (int, error) {
-	out := buffer.Batch(buffer.Len())
-	outbytes, _ := ser.SerializeBatch(out)
-	f := bufio.NewWriter(os.Stdout)
-	defer f.Flush()
-	f.Write(outbytes)
-	return len(out), nil
+// Interruptible is implemented by outputs with waits (e.g. write retries)
+// that should end as soon as the sink is stopped.
+type Interruptible interface {
+	SetExit(chExit <-chan bool)
 }
 
-func startOutputSender() {
-	flushTicker := time.NewTicker(flushPeriod)
-	defer flushTicker.Stop()
+// Expirer is implemented by outputs keeping state by series that should be
+// released when an instance is undiscovered.
+type Expirer interface {
+	Expire(tags map[string]string) int
+}
 
-	log.Infof("[OUTPUT] beginning OutputSender thread")
-	for {
-		select {
-		case <-chExit:
-			// need to flush all data
-			n, err := flushData()
-			log.Infof("[OUTPUT] Flushed %d metrics: with error:%s", n, err)
-			log.Infof("[OUTPUT] EXIT from Output sender process for device:")
-			return
-		case <-flushTicker.C:
-			n, err := flushData()
+// NewOutput creates the output for the sink type
+func NewOutput(cfg *config.OutputSinkConfig, stats *sinkCounters) (Output, error) {
+	switch cfg.Type {
+	case "stdout":
+		return NewStdoutOutput(stats), nil
+	case "file":
+		return NewFileOutput(cfg.File, stats), nil
+	case "prometheus":
+		return NewPromExporter(cfg.ListenAddress, cfg.Path), nil
+	case "influxdb", "influxdb_v2":
+		return NewInfluxOutput(cfg, stats), nil
+	}
+	return nil, fmt.Errorf("unknown output type %s", cfg.Type)
+}
+
+func Init(cfg *config.OutputConfig) error {
+	sinks = nil
+	for _, sc := range cfg.AllSinks {
+		s, err := NewSink(sc)
+		if err != nil {
+			return fmt.Errorf("Error on init output sink %s: %s", sc.Name, err)
+		}
+		if cfg.SpoolEnabled && s.buffer != nil {
+			dir := filepath.Join(dataDir, "spool", sc.Name)
+			s.spool, err = NewSpool(dir, cfg.SpoolMaxSizeMB*1024*1024)
 			if err != nil {
-				log.Infof("[OUTPUT] Flushed %d metrics: with error:%s", n, err)
-			} else {
-				log.Infof("[OUTPUT] Flushed %d metrics: without error", n)
+				return fmt.Errorf("Error on init spool for output sink %s: %s", sc.Name, err)
 			}
+			log.Infof("[OUTPUT] Sink [%s] spool on %s with %d pending segments", sc.Name, dir, s.spool.Len())
+		}
+		sinks = append(sinks, s)
+	}
+	for _, s := range sinks {
+		s.Start(&wg)
+	}
+	return nil
+}
+
+// End release Output process, flushing all sink pending data
+func End() {
+	for _, s := range sinks {
+		s.Stop()
+	}
+	wg.Wait()
+}
+
+// SendMetrics sends the metrics to all sinks with matching filters
+func SendMetrics(metrics []telegraf.Metric) {
+	for _, s := range sinks {
+		s.Add(metrics)
+	}
+}
 
+// ExpireSeries removes from the stateful outputs all series with the given tags
+func ExpireSeries(tags map[string]string) {
+	for _, s := range sinks {
+		e, ok := s.output.(Expirer)
+		if !ok {
+			continue
 		}
+		n := e.Expire(tags)
+		log.Infof("[OUTPUT] Sink [%s] Expired %d series with tags %+v", s.cfg.Name, n, tags)
 	}
 }
@@ -0,0 +1,310 @@
+package output
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/toni-moreno/oracle_collector/pkg/config"
+)
+
+var (
+	invalidMetricChars = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+	invalidLabelChars  = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+	// metric configs indexed by measurement name, used to get HELP and TYPE info
+	metricCfgMutex sync.RWMutex
+	metricCfgs     = make(map[string]*config.OracleMetricConfig)
+)
+
+// RegisterMetricConfig stores the metric config to describe all series
+// with the same measurement name in the prometheus exposition.
+func RegisterMetricConfig(mc *config.OracleMetricConfig) {
+	metricCfgMutex.Lock()
+	defer metricCfgMutex.Unlock()
+	metricCfgs[mc.Context] = mc
+}
+
+// UnregisterMetricConfig removes the metric config (if not registered again
+// by other group)
+func UnregisterMetricConfig(mc *config.OracleMetricConfig) {
+	metricCfgMutex.Lock()
+	defer metricCfgMutex.Unlock()
+	if metricCfgs[mc.Context] == mc {
+		delete(metricCfgs, mc.Context)
+	}
+}
+
+func getMetricConfig(measurement string) *config.OracleMetricConfig {
+	metricCfgMutex.RLock()
+	defer metricCfgMutex.RUnlock()
+	return metricCfgs[measurement]
+}
+
+type promSeries struct {
+	labels map[string]string
+	value  float64
+}
+
+type promFamily struct {
+	help   string
+	typ    string
+	series map[string]*promSeries
+}
+
+// PromExporter keeps the last value of each series and exposes them
+// in the prometheus text format.
+type PromExporter struct {
+	sync.Mutex
+	address  string
+	path     string
+	families map[string]*promFamily
+	server   *http.Server
+}
+
+// NewPromExporter creates an empty exporter
+func NewPromExporter(address string, path string) *PromExporter {
+	return &PromExporter{
+		address:  address,
+		path:     path,
+		families: make(map[string]*promFamily),
+	}
+}
+
+func sanitizeMetricName(name string) string {
+	s := invalidMetricChars.ReplaceAllString(name, "_")
+	if len(s) > 0 && s[0] >= '0' && s[0] <= '9' {
+		s = "_" + s
+	}
+	return s
+}
+
+func sanitizeLabelName(name string) string {
+	s := invalidLabelChars.ReplaceAllString(name, "_")
+	if len(s) > 0 && s[0] >= '0' && s[0] <= '9' {
+		s = "_" + s
+	}
+	return s
+}
+
+func seriesKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k)
+		sb.WriteString("=")
+		sb.WriteString(labels[k])
+		sb.WriteString(",")
+	}
+	return sb.String()
+}
+
+// promValue converts field values to float, strings are not exposed
+func promValue(v interface{}) (float64, bool) {
+	switch value := v.(type) {
+	case float64:
+		return value, true
+	case float32:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case int32:
+		return float64(value), true
+	case int:
+		return float64(value), true
+	case uint64:
+		return float64(value), true
+	case uint32:
+		return float64(value), true
+	case uint:
+		return float64(value), true
+	case bool:
+		if value {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// descAndType gets the HELP and TYPE for a field of a configured measurement,
+// transposed metrics (fieldtoappend) get them from the "value" field.
+func descAndType(measurement string, field string) (string, string) {
+	mc := getMetricConfig(measurement)
+	if mc == nil {
+		return "", "untyped"
+	}
+	desc, ok := mc.MetricsDesc[field]
+	if !ok {
+		desc = mc.MetricsDesc["value"]
+	}
+	t, ok := mc.MetricsType[field]
+	if !ok {
+		t = mc.MetricsType["value"]
+	}
+	switch t {
+	case "COUNTER", "counter":
+		return desc, "counter"
+	case "INTEGER", "integer", "FLOAT", "float", "bool", "BOOL", "BOOLEAN":
+		return desc, "gauge"
+	}
+	return desc, "untyped"
+}
+
+// Direct the exporter gets the metrics without buffering
+func (pe *PromExporter) Direct() bool {
+	return true
+}
+
+// Write stores the last value of all numeric fields in the metrics
+func (pe *PromExporter) Write(metrics []telegraf.Metric) error {
+	pe.Lock()
+	defer pe.Unlock()
+	for _, m := range metrics {
+		labels := make(map[string]string)
+		for _, t := range m.TagList() {
+			labels[sanitizeLabelName(t.Key)] = t.Value
+		}
+		key := seriesKey(labels)
+		for _, f := range m.FieldList() {
+			value, ok := promValue(f.Value)
+			if !ok {
+				continue
+			}
+			name := sanitizeMetricName(m.Name() + "_" + f.Key)
+			fam, ok := pe.families[name]
+			if !ok {
+				fam = &promFamily{series: make(map[string]*promSeries)}
+				pe.families[name] = fam
+			}
+			// the metric config could be changed on reload
+			fam.help, fam.typ = descAndType(m.Name(), f.Key)
+			fam.series[key] = &promSeries{labels: labels, value: value}
+		}
+	}
+	return nil
+}
+
+// Expire removes all series with matching all the given tags
+func (pe *PromExporter) Expire(tags map[string]string) int {
+	pe.Lock()
+	defer pe.Unlock()
+	removed := 0
+	for name, fam := range pe.families {
+		for key, s := range fam.series {
+			match := true
+			for k, v := range tags {
+				if s.labels[sanitizeLabelName(k)] != v {
+					match = false
+					break
+				}
+			}
+			if match {
+				delete(fam.series, key)
+				removed++
+			}
+		}
+		if len(fam.series) == 0 {
+			delete(pe.families, name)
+		}
+	}
+	return removed
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// writeText writes all families in the prometheus text format
+func (pe *PromExporter) writeText(out io.Writer) error {
+	pe.Lock()
+	defer pe.Unlock()
+	w := bufio.NewWriter(out)
+	names := make([]string, 0, len(pe.families))
+	for name := range pe.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fam := pe.families[name]
+		if len(fam.help) > 0 {
+			fmt.Fprintf(w, "# HELP %s %s\n", name, helpEscaper.Replace(fam.help))
+		}
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, fam.typ)
+		keys := make([]string, 0, len(fam.series))
+		for k := range fam.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			s := fam.series[k]
+			w.WriteString(name)
+			if len(s.labels) > 0 {
+				lnames := make([]string, 0, len(s.labels))
+				for l := range s.labels {
+					lnames = append(lnames, l)
+				}
+				sort.Strings(lnames)
+				w.WriteString("{")
+				for i, l := range lnames {
+					if i > 0 {
+						w.WriteString(",")
+					}
+					fmt.Fprintf(w, "%s=\"%s\"", l, labelEscaper.Replace(s.labels[l]))
+				}
+				w.WriteString("}")
+			}
+			w.WriteString(" ")
+			w.WriteString(strconv.FormatFloat(s.value, 'g', -1, 64))
+			w.WriteString("\n")
+		}
+	}
+	return w.Flush()
+}
+
+// ServeHTTP handles the metrics request
+func (pe *PromExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := pe.writeText(w); err != nil {
+		log.Warnf("[OUTPUT] Error on writing prometheus metrics: %s", err)
+	}
+}
+
+// Connect begins listening on the address for metric requests
+func (pe *PromExporter) Connect() error {
+	mux := http.NewServeMux()
+	mux.Handle(pe.path, pe)
+	pe.server = &http.Server{Addr: pe.address, Handler: mux}
+	go func() {
+		log.Infof("[OUTPUT] Prometheus exporter listening on %s%s", pe.address, pe.path)
+		err := pe.server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Errorf("[OUTPUT] Prometheus exporter error: %s", err)
+		}
+	}()
+	return nil
+}
+
+// Close stops the listener
+func (pe *PromExporter) Close() error {
+	if pe.server == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return pe.server.Shutdown(ctx)
+}
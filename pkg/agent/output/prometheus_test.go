@@ -0,0 +1,69 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/toni-moreno/oracle_collector/pkg/config"
+)
+
+func TestPromExporterReloadedMetricConfig(t *testing.T) {
+	pe := NewPromExporter(":0", "/metrics")
+	m := []telegraf.Metric{metric.New("oracle_prom_test", map[string]string{"instance": "ORCL"}, map[string]interface{}{"value": 1}, time.Now())}
+	tests := []struct {
+		mc   *config.OracleMetricConfig
+		want []string
+	}{
+		{
+			mc:   nil,
+			want: []string{"# TYPE oracle_prom_test_value untyped\n"},
+		},
+		{
+			mc: &config.OracleMetricConfig{
+				Context:     "oracle_prom_test",
+				MetricsDesc: map[string]string{"value": "Test gauge"},
+				MetricsType: map[string]string{"value": "INTEGER"},
+			},
+			want: []string{"# HELP oracle_prom_test_value Test gauge\n", "# TYPE oracle_prom_test_value gauge\n"},
+		},
+		{
+			mc: &config.OracleMetricConfig{
+				Context:     "oracle_prom_test",
+				MetricsDesc: map[string]string{"value": "Test counter"},
+				MetricsType: map[string]string{"value": "COUNTER"},
+			},
+			want: []string{"# HELP oracle_prom_test_value Test counter\n", "# TYPE oracle_prom_test_value counter\n"},
+		},
+	}
+	var prev *config.OracleMetricConfig
+	for i, tt := range tests {
+		// as done on reload: the new config is registered before removing the old one
+		if tt.mc != nil {
+			RegisterMetricConfig(tt.mc)
+		}
+		if prev != nil {
+			UnregisterMetricConfig(prev)
+		}
+		prev = tt.mc
+		if err := pe.Write(m); err != nil {
+			t.Fatalf("unexpected write error: %s", err)
+		}
+		var b bytes.Buffer
+		if err := pe.writeText(&b); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(b.String(), w) {
+				t.Errorf("step %d: %q not found in:\n%s", i, w, b.String())
+			}
+		}
+		if !strings.Contains(b.String(), `oracle_prom_test_value{instance="ORCL"} 1`) {
+			t.Errorf("step %d: series not found in:\n%s", i, b.String())
+		}
+	}
+	UnregisterMetricConfig(prev)
+}
@@ -0,0 +1,83 @@
+package output
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/serializers"
+	"github.com/toni-moreno/oracle_collector/pkg/config"
+)
+
+var csvTimestampFormats = map[time.Duration]string{
+	time.Second:      "unix",
+	time.Millisecond: "unix_ms",
+	time.Microsecond: "unix_us",
+	time.Nanosecond:  "unix_ns",
+}
+
+// truncSerializer truncates the metric timestamps to the configured precision
+// for formats without timestamp units (influx is always in ns, prometheus in ms)
+type truncSerializer struct {
+	serializers.Serializer
+	precision time.Duration
+}
+
+func (ts *truncSerializer) truncate(m telegraf.Metric) telegraf.Metric {
+	t := m.Time().Truncate(ts.precision)
+	if t.Equal(m.Time()) {
+		return m
+	}
+	// metrics are shared by all sinks: should not be modified
+	c := m.Copy()
+	c.SetTime(t)
+	return c
+}
+
+func (ts *truncSerializer) Serialize(m telegraf.Metric) ([]byte, error) {
+	return ts.Serializer.Serialize(ts.truncate(m))
+}
+
+func (ts *truncSerializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	tm := make([]telegraf.Metric, 0, len(metrics))
+	for _, m := range metrics {
+		tm = append(tm, ts.truncate(m))
+	}
+	return ts.Serializer.SerializeBatch(tm)
+}
+
+// NewSerializer creates the serializer for the sink data format
+func NewSerializer(cfg *config.SerializerConfig) (serializers.Serializer, error) {
+	precision := cfg.GetTimestampPrecision()
+	sc := &serializers.Config{
+		DataFormat:         cfg.DataFormat,
+		TimestampUnits:     precision,
+		Prefix:             cfg.GraphitePrefix,
+		Template:           cfg.GraphiteTemplate,
+		GraphiteTagSupport: cfg.GraphiteTagSupport,
+		Carbon2Format:      cfg.Carbon2Format,
+		CSVSeparator:       cfg.CSVSeparator,
+		CSVHeader:          cfg.CSVHeader,
+	}
+	// formats with fixed timestamp units are truncated before serializing
+	truncate := false
+	switch cfg.DataFormat {
+	case "influx":
+		truncate = true
+	case "prometheus", "openmetrics":
+		// both use the text exposition format
+		sc.DataFormat = "prometheus"
+		sc.PrometheusExportTimestamp = true
+		truncate = true
+	case "csv":
+		sc.TimestampFormat = csvTimestampFormats[precision]
+	}
+	ser, err := serializers.NewSerializer(sc)
+	if err != nil {
+		return nil, fmt.Errorf("Error in init serializer %s: %s", cfg.DataFormat, err)
+	}
+	if truncate && precision > time.Nanosecond {
+		return &truncSerializer{Serializer: ser, precision: precision}, nil
+	}
+	return ser, nil
+}
@@ -0,0 +1,308 @@
+package output
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/plugins/serializers"
+	"github.com/toni-moreno/oracle_collector/pkg/config"
+)
+
+// sinkFilter decides which metrics will be routed to the sink
+type sinkFilter struct {
+	namePass filter.Filter
+	nameDrop filter.Filter
+	tagPass  map[string]filter.Filter
+	tagDrop  map[string]filter.Filter
+}
+
+func compileTagFilters(tf map[string][]string) (map[string]filter.Filter, error) {
+	if len(tf) == 0 {
+		return nil, nil
+	}
+	ret := make(map[string]filter.Filter)
+	for k, v := range tf {
+		f, err := filter.Compile(v)
+		if err != nil {
+			return nil, fmt.Errorf("Error on tag filter %s: %s", k, err)
+		}
+		ret[k] = f
+	}
+	return ret, nil
+}
+
+func newSinkFilter(cfg *config.OutputSinkConfig) (*sinkFilter, error) {
+	var err error
+	sf := &sinkFilter{}
+	if sf.namePass, err = filter.Compile(cfg.NamePass); err != nil {
+		return nil, fmt.Errorf("Error on namepass filter: %s", err)
+	}
+	if sf.nameDrop, err = filter.Compile(cfg.NameDrop); err != nil {
+		return nil, fmt.Errorf("Error on namedrop filter: %s", err)
+	}
+	if sf.tagPass, err = compileTagFilters(cfg.TagPass); err != nil {
+		return nil, err
+	}
+	if sf.tagDrop, err = compileTagFilters(cfg.TagDrop); err != nil {
+		return nil, err
+	}
+	return sf, nil
+}
+
+func matchTags(tf map[string]filter.Filter, m telegraf.Metric) bool {
+	for _, tag := range m.TagList() {
+		if f, ok := tf[tag.Key]; ok && f.Match(tag.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns true if the metric should be sent to the sink
+func (sf *sinkFilter) Match(m telegraf.Metric) bool {
+	if sf.namePass != nil && !sf.namePass.Match(m.Name()) {
+		return false
+	}
+	if sf.nameDrop != nil && sf.nameDrop.Match(m.Name()) {
+		return false
+	}
+	if sf.tagPass != nil && !matchTags(sf.tagPass, m) {
+		return false
+	}
+	if sf.tagDrop != nil && matchTags(sf.tagDrop, m) {
+		return false
+	}
+	return true
+}
+
+// Sink handles the buffering, filtering and flushing of metrics to one Output
+type Sink struct {
+	cfg    *config.OutputSinkConfig
+	output Output
+	filter *sinkFilter
+	buffer *models.Buffer
+	spool  *Spool
+	direct bool
+	stats  *sinkCounters
+	chExit chan bool
+	// only one flush at a time
+	flushMutex sync.Mutex
+}
+
+// NewSink creates the sink with its output and serializer
+func NewSink(cfg *config.OutputSinkConfig) (*Sink, error) {
+	stats := newSinkCounters(cfg.Name, cfg.Type)
+	o, err := NewOutput(cfg, stats)
+	if err != nil {
+		return nil, err
+	}
+	f, err := newSinkFilter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sink{
+		cfg:    cfg,
+		output: o,
+		filter: f,
+		stats:  stats,
+		chExit: make(chan bool),
+	}
+	if so, ok := o.(serializers.SerializerOutput); ok {
+		ser, err := NewSerializer(&cfg.SerializerConfig)
+		if err != nil {
+			return nil, err
+		}
+		so.SetSerializer(ser)
+	}
+	if in, ok := o.(Interruptible); ok {
+		in.SetExit(s.chExit)
+	}
+	if d, ok := o.(DirectOutput); ok && d.Direct() {
+		s.direct = true
+	} else {
+		s.buffer = models.NewBuffer("oracle_collector", cfg.Name, cfg.BufferSize)
+	}
+	return s, nil
+}
+
+// Add filters and stores the metrics in the sink buffer
+func (s *Sink) Add(metrics []telegraf.Metric) {
+	filtered := make([]telegraf.Metric, 0, len(metrics))
+	for _, m := range metrics {
+		if s.filter.Match(m) {
+			filtered = append(filtered, m)
+		}
+	}
+	if len(filtered) == 0 {
+		return
+	}
+	if s.direct {
+		if err := s.output.Write(filtered); err != nil {
+			log.Warnf("[OUTPUT] Sink [%s] Error on write: %s", s.cfg.Name, err)
+			return
+		}
+		s.stats.addMetrics(len(filtered), 0)
+		return
+	}
+	// with spool, buffer content goes to disk instead of being dropped
+	if s.spool != nil && s.buffer.Len()+len(filtered) > s.cfg.BufferSize && s.flushMutex.TryLock() {
+		s.spillBuffer()
+		s.flushMutex.Unlock()
+	}
+	dropped := s.buffer.Add(filtered...)
+	if dropped > 0 {
+		s.stats.addMetrics(0, dropped)
+		log.Warnf("[OUTPUT] Sink [%s] Dropped metrics %d", s.cfg.Name, dropped)
+	}
+}
+
+// spillBuffer moves all buffered metrics to the spool (flushMutex should be locked)
+func (s *Sink) spillBuffer() {
+	for s.buffer.Len() > 0 {
+		size := s.buffer.Len()
+		if s.cfg.BatchSize > 0 && size > s.cfg.BatchSize {
+			size = s.cfg.BatchSize
+		}
+		batch := s.buffer.Batch(size)
+		if err := s.spool.Push(batch); err != nil {
+			log.Errorf("[OUTPUT] Sink [%s] Error on spooling %d metrics: %s", s.cfg.Name, len(batch), err)
+			s.buffer.Reject(batch)
+			return
+		}
+		s.buffer.Accept(batch)
+	}
+}
+
+// flushSpool writes all spooled segments in order, oldest first
+func (s *Sink) flushSpool() (int, error) {
+	n := 0
+	for s.spool.Len() > 0 {
+		batch, name, err := s.spool.Peek()
+		if err != nil {
+			log.Errorf("[OUTPUT] Sink [%s] Discarding unreadable spool segment %s: %s", s.cfg.Name, name, err)
+			s.spool.Remove(name)
+			continue
+		}
+		err = s.output.Write(batch)
+		if _, ok := err.(*PermanentError); ok {
+			log.Errorf("[OUTPUT] Sink [%s] Dropped %d spooled metrics on permanent error: %s", s.cfg.Name, len(batch), err)
+			s.spool.Remove(name)
+			s.stats.addMetrics(0, len(batch))
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		s.spool.Remove(name)
+		s.stats.addMetrics(len(batch), 0)
+		n += len(batch)
+	}
+	return n, nil
+}
+
+// flushData writes all buffered metrics in batches of batch_size
+func (s *Sink) flushData() (int, error) {
+	s.flushMutex.Lock()
+	defer s.flushMutex.Unlock()
+	n := 0
+	if s.spool != nil {
+		var err error
+		n, err = s.flushSpool()
+		if err != nil {
+			// keep order: new data should wait after the spooled one
+			s.spillBuffer()
+			return n, err
+		}
+	}
+	for {
+		size := s.buffer.Len()
+		if size == 0 {
+			return n, nil
+		}
+		if s.cfg.BatchSize > 0 && size > s.cfg.BatchSize {
+			size = s.cfg.BatchSize
+		}
+		batch := s.buffer.Batch(size)
+		if len(batch) == 0 {
+			return n, nil
+		}
+		err := s.output.Write(batch)
+		if _, ok := err.(*PermanentError); ok {
+			// retrying will fail again: batch should be discarded
+			log.Errorf("[OUTPUT] Sink [%s] Dropped %d metrics on permanent error: %s", s.cfg.Name, len(batch), err)
+			s.buffer.Accept(batch)
+			s.stats.addMetrics(0, len(batch))
+			continue
+		}
+		if err != nil {
+			// metrics will remain in the buffer (or spool) until the next flush
+			s.buffer.Reject(batch)
+			if s.spool != nil {
+				s.spillBuffer()
+			}
+			return n, err
+		}
+		s.buffer.Accept(batch)
+		s.stats.addMetrics(len(batch), 0)
+		n += len(batch)
+	}
+}
+
+// Start connects the output and begins the flush process
+func (s *Sink) Start(wg *sync.WaitGroup) {
+	if err := s.output.Connect(); err != nil {
+		log.Errorf("[OUTPUT] Sink [%s] Error on connect: %s", s.cfg.Name, err)
+	}
+	if s.direct {
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.startOutputSender()
+	}()
+}
+
+// Stop ends the flush process and releases the output
+func (s *Sink) Stop() {
+	if s.direct {
+		s.close()
+		return
+	}
+	close(s.chExit)
+}
+
+func (s *Sink) close() {
+	if err := s.output.Close(); err != nil {
+		log.Warnf("[OUTPUT] Sink [%s] Error on close: %s", s.cfg.Name, err)
+	}
+}
+
+func (s *Sink) startOutputSender() {
+	flushTicker := time.NewTicker(s.cfg.FlushPeriod)
+	defer flushTicker.Stop()
+
+	log.Infof("[OUTPUT] beginning OutputSender thread for sink [%s] type [%s]", s.cfg.Name, s.cfg.Type)
+	for {
+		select {
+		case <-s.chExit:
+			// need to flush all data
+			n, err := s.flushData()
+			log.Infof("[OUTPUT] Sink [%s] Flushed %d metrics: with error:%v", s.cfg.Name, n, err)
+			log.Infof("[OUTPUT] EXIT from Output sender process for sink [%s]", s.cfg.Name)
+			s.close()
+			return
+		case <-flushTicker.C:
+			n, err := s.flushData()
+			if err != nil {
+				log.Infof("[OUTPUT] Sink [%s] Flushed %d metrics: with error:%s", s.cfg.Name, n, err)
+			} else {
+				log.Infof("[OUTPUT] Sink [%s] Flushed %d metrics: without error", s.cfg.Name, n)
+			}
+		}
+	}
+}
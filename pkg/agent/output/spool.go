@@ -0,0 +1,156 @@
+package output
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/parsers/influx"
+	serinflux "github.com/influxdata/telegraf/plugins/serializers/influx"
+)
+
+const segmentExt = ".seg"
+
+// Spool persists un-flushed batches in segment files (one batch per file)
+// in InfluxDB line protocol, to be replayed in order when the sink recovers.
+type Spool struct {
+	sync.Mutex
+	dir      string
+	maxSize  int64
+	segments []string
+	sizes    map[string]int64
+	size     int64
+	seq      uint64
+	evicted  int64
+	ser      *serinflux.Serializer
+	parser   *influx.Parser
+}
+
+// NewSpool opens (or creates) the spool directory and loads existing segments
+func NewSpool(dir string, maxSize int64) (*Spool, error) {
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, err
+	}
+	sp := &Spool{
+		dir:     dir,
+		maxSize: maxSize,
+		sizes:   make(map[string]int64),
+		ser:     serinflux.NewSerializer(),
+		parser:  &influx.Parser{},
+	}
+	sp.ser.SetFieldSortOrder(serinflux.NoSortFields)
+	sp.ser.SetFieldTypeSupport(serinflux.UintSupport)
+	if err := sp.parser.Init(); err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(f.Name(), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		if seq > sp.seq {
+			sp.seq = seq
+		}
+		sp.segments = append(sp.segments, f.Name())
+		sp.sizes[f.Name()] = f.Size()
+		sp.size += f.Size()
+	}
+	// names are zero padded sequence numbers: sorting them sorts by age
+	sort.Strings(sp.segments)
+	return sp, nil
+}
+
+// Push writes the batch as a new segment, evicting the oldest ones if needed
+func (sp *Spool) Push(metrics []telegraf.Metric) error {
+	sp.Lock()
+	defer sp.Unlock()
+	data, err := sp.ser.SerializeBatch(metrics)
+	if err != nil {
+		return err
+	}
+	sp.seq++
+	name := fmt.Sprintf("%020d%s", sp.seq, segmentExt)
+	tmp := filepath.Join(sp.dir, name+".tmp")
+	if err := ioutil.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(sp.dir, name)); err != nil {
+		return err
+	}
+	sp.segments = append(sp.segments, name)
+	sp.sizes[name] = int64(len(data))
+	sp.size += int64(len(data))
+	// oldest first eviction, the last segment is always kept
+	for sp.maxSize > 0 && sp.size > sp.maxSize && len(sp.segments) > 1 {
+		oldest := sp.segments[0]
+		log.Warnf("[OUTPUT] Spool %s full (%d bytes): evicting segment %s", sp.dir, sp.size, oldest)
+		sp.remove(oldest)
+		sp.evicted++
+	}
+	return nil
+}
+
+// Peek returns the metrics in the oldest segment and its name
+func (sp *Spool) Peek() ([]telegraf.Metric, string, error) {
+	sp.Lock()
+	defer sp.Unlock()
+	if len(sp.segments) == 0 {
+		return nil, "", nil
+	}
+	name := sp.segments[0]
+	data, err := ioutil.ReadFile(filepath.Join(sp.dir, name))
+	if err != nil {
+		return nil, name, err
+	}
+	metrics, err := sp.parser.Parse(data)
+	return metrics, name, err
+}
+
+// Remove deletes the segment once written
+func (sp *Spool) Remove(name string) {
+	sp.Lock()
+	defer sp.Unlock()
+	sp.remove(name)
+}
+
+func (sp *Spool) remove(name string) {
+	if err := os.Remove(filepath.Join(sp.dir, name)); err != nil && !os.IsNotExist(err) {
+		log.Warnf("[OUTPUT] Error on removing spool segment %s: %s", name, err)
+	}
+	for i, s := range sp.segments {
+		if s == name {
+			sp.segments = append(sp.segments[:i], sp.segments[i+1:]...)
+			break
+		}
+	}
+	sp.size -= sp.sizes[name]
+	delete(sp.sizes, name)
+}
+
+// Len returns the number of segments in the spool
+func (sp *Spool) Len() int {
+	sp.Lock()
+	defer sp.Unlock()
+	return len(sp.segments)
+}
+
+// Stats returns the number of segments, the disk size and evicted segments
+func (sp *Spool) Stats() (int, int64, int64) {
+	sp.Lock()
+	defer sp.Unlock()
+	return len(sp.segments), sp.size, sp.evicted
+}
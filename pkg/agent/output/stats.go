@@ -0,0 +1,78 @@
+package output
+
+import (
+	"sync"
+	"time"
+)
+
+// SinkStats has the counters for each sink, reported by the self monitor
+type SinkStats struct {
+	Name           string
+	Type           string
+	BufferLen      int
+	MetricsWritten int64
+	MetricsDropped int64
+	Writes         int64
+	WriteErrors    int64
+	Bytes          int64
+	Retries        int64
+	LastStatus     int
+	LastLatency    time.Duration
+	SpoolSegments  int
+	SpoolBytes     int64
+	SpoolEvicted   int64
+}
+
+// sinkCounters guards the sink stats updated from the sink and its output
+type sinkCounters struct {
+	sync.Mutex
+	st SinkStats
+}
+
+func newSinkCounters(name string, typ string) *sinkCounters {
+	return &sinkCounters{st: SinkStats{Name: name, Type: typ}}
+}
+
+// addWrite counts the result of an output write
+func (sc *sinkCounters) addWrite(bytes int, status int, retries int, latency time.Duration, err error) {
+	sc.Lock()
+	defer sc.Unlock()
+	sc.st.Writes++
+	if err != nil {
+		sc.st.WriteErrors++
+	}
+	sc.st.Bytes += int64(bytes)
+	sc.st.Retries += int64(retries)
+	sc.st.LastStatus = status
+	sc.st.LastLatency = latency
+}
+
+func (sc *sinkCounters) addMetrics(written int, dropped int) {
+	sc.Lock()
+	defer sc.Unlock()
+	sc.st.MetricsWritten += int64(written)
+	sc.st.MetricsDropped += int64(dropped)
+}
+
+// Snapshot returns a copy of the current counters
+func (sc *sinkCounters) Snapshot() SinkStats {
+	sc.Lock()
+	defer sc.Unlock()
+	return sc.st
+}
+
+// GetStats returns the stats for all configured sinks
+func GetStats() []SinkStats {
+	ret := []SinkStats{}
+	for _, s := range sinks {
+		st := s.stats.Snapshot()
+		if s.buffer != nil {
+			st.BufferLen = s.buffer.Len()
+		}
+		if s.spool != nil {
+			st.SpoolSegments, st.SpoolBytes, st.SpoolEvicted = s.spool.Stats()
+		}
+		ret = append(ret, st)
+	}
+	return ret
+}
@@ -0,0 +1,88 @@
+package output
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/serializers"
+)
+
+// stdout could be shared by more than one sink
+var stdoutMutex sync.Mutex
+
+// WriterOutput writes serialized metrics to a file or stdout
+type WriterOutput struct {
+	stdout bool
+	file   string
+	out    io.WriteCloser
+	ser    serializers.Serializer
+	stats  *sinkCounters
+}
+
+// NewStdoutOutput creates an output to the process stdout
+func NewStdoutOutput(stats *sinkCounters) *WriterOutput {
+	return &WriterOutput{stdout: true, stats: stats}
+}
+
+// NewFileOutput creates an output appending to the file
+func NewFileOutput(file string, stats *sinkCounters) *WriterOutput {
+	return &WriterOutput{file: file, stats: stats}
+}
+
+func (wo *WriterOutput) SetSerializer(ser serializers.Serializer) {
+	wo.ser = ser
+}
+
+func (wo *WriterOutput) Connect() error {
+	if wo.stdout {
+		return nil
+	}
+	f, err := os.OpenFile(wo.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	wo.out = f
+	return nil
+}
+
+func (wo *WriterOutput) Write(metrics []telegraf.Metric) error {
+	start := time.Now()
+	outbytes, err := wo.ser.SerializeBatch(metrics)
+	if err != nil {
+		return err
+	}
+	err = wo.write(outbytes)
+	wo.stats.addWrite(len(outbytes), 0, 0, time.Since(start), err)
+	return err
+}
+
+func (wo *WriterOutput) write(outbytes []byte) error {
+	var err error
+	if wo.stdout {
+		stdoutMutex.Lock()
+		defer stdoutMutex.Unlock()
+		f := bufio.NewWriter(os.Stdout)
+		defer f.Flush()
+		_, err = f.Write(outbytes)
+		return err
+	}
+	// file could not be opened on connect
+	if wo.out == nil {
+		if err := wo.Connect(); err != nil {
+			return err
+		}
+	}
+	_, err = wo.out.Write(outbytes)
+	return err
+}
+
+func (wo *WriterOutput) Close() error {
+	if wo.out == nil {
+		return nil
+	}
+	return wo.out.Close()
+}
@@ -166,6 +166,12 @@ func startSelfmonCollector() {
 
 			return
 		case <-flushTicker.C:
+			n, err := collectOutputStats()
+			if err != nil {
+				log.Infof("[SELF_MON] Flushed %d output stats metrics: with error:%s", n, err)
+			} else {
+				log.Infof("[SELF_MON] Flushed %d output stats metrics: OK", n)
+			}
 			/*
 				n, err := collectRuntimeStats()
 				if err != nil {
@@ -173,7 +179,7 @@ func startSelfmonCollector() {
 				} else {
 					log.Infof("[SELF_MON] Flushed %d metrics: OK", n)
 				}*/
-			n, err := collectLegacyRuntimeStats()
+			n, err = collectLegacyRuntimeStats()
 			if err != nil {
 				log.Infof("[SELF_MON] Flushed %d Legacy runtime metrics: with error:%s", n, err)
 			} else {
@@ -184,7 +190,9 @@ func startSelfmonCollector() {
 	}
 }
 
-func SendQueryStat(extraLabels map[string]string, mgc *config.OracleMetricGroupConfig, mc *config.OracleMetricConfig, n int, t time.Duration) {
+// SendQueryStat sends the collect_stats for the metric query, with the
+// query error if failed
+func SendQueryStat(extraLabels map[string]string, mgc *config.OracleMetricGroupConfig, mc *config.OracleMetricConfig, n int, t time.Duration, queryErr error) {
 	result := []telegraf.Metric{}
 
 	tags := make(map[string]string)
@@ -203,6 +211,10 @@ func SendQueryStat(extraLabels map[string]string, mgc *config.OracleMetricGroupC
 	fields := make(map[string]interface{})
 	fields["num_metrics"] = n
 	fields["duration_us"] = t.Microseconds()
+	fields["query_ok"] = queryErr == nil
+	if queryErr != nil {
+		fields["error"] = config.Redact(queryErr.Error())
+	}
 	now := time.Now()
 	meas_name := "collect_stats"
 	if len(conf.Prefix) > 0 {
@@ -223,6 +235,7 @@ func SendDiscoveryMetrics(discovered_all int,
 	undiscovered_str []string,
 	err_con_sids []string,
 	skipped_con_sids []string,
+	pending_sids []string,
 ) {
 	result := []telegraf.Metric{}
 
@@ -252,6 +265,9 @@ func SendDiscoveryMetrics(discovered_all int,
 	fields["undiscovered_sid_names"] = strings.Join(undiscovered_str, ":")
 	fields["discovered_sid_names"] = strings.Join(discovered_new_str, ":")
 	fields["errconnect_sid_names"] = strings.Join(err_con_sids, ":")
+	sort.Strings(pending_sids)
+	fields["pending"] = len(pending_sids)
+	fields["pending_sid_names"] = strings.Join(pending_sids, ":")
 	// fields["errconnect_skipped_sid_names"] = strings.Join(skipped_con_sids, ":")
 	now := time.Now()
 	meas_name := "discover_stats"
@@ -263,6 +279,36 @@ func SendDiscoveryMetrics(discovered_all int,
 	output.SendMetrics(result)
 }
 
+// SendDiscoveryProviderStats sends the discover_stats for each discovery provider
+func SendDiscoveryProviderStats(provider string, targets int, duplicated int, discErr error, t time.Duration) {
+	result := []telegraf.Metric{}
+
+	tags := make(map[string]string)
+	// and then added Extra tags from sefl-monitor config
+	for k, v := range conf.ExtraLabels {
+		tags[k] = v
+	}
+	tags["provider"] = provider
+	fields := make(map[string]interface{})
+	fields["targets"] = targets
+	fields["duplicated"] = duplicated
+	fields["errors"] = 0
+	fields["error"] = ""
+	if discErr != nil {
+		fields["errors"] = 1
+		fields["error"] = config.Redact(discErr.Error())
+	}
+	fields["duration_us"] = t.Microseconds()
+	now := time.Now()
+	meas_name := "discover_stats"
+	if len(conf.Prefix) > 0 {
+		meas_name = conf.Prefix + meas_name
+	}
+	m := metric.New(meas_name, tags, fields, now)
+	result = append(result, m)
+	output.SendMetrics(result)
+}
+
 func SendSQLDriverStat(inst string, s sql.DBStats) {
 	result := []telegraf.Metric{}
 
@@ -294,6 +340,98 @@ func SendSQLDriverStat(inst string, s sql.DBStats) {
 	output.SendMetrics(result)
 }
 
+// SendInstanceStateStats sends the health state of the instance
+func SendInstanceStateStats(inst string, state string, duration time.Duration, reconnects int) {
+	result := []telegraf.Metric{}
+
+	tags := make(map[string]string)
+
+	// and then added Extra tags from sefl-monitor config
+	for k, v := range conf.ExtraLabels {
+		tags[k] = v
+	}
+
+	tags["instance"] = inst
+	fields := make(map[string]interface{})
+	fields["state"] = state
+	fields["state_duration_sec"] = int64(duration.Seconds())
+	fields["reconnects"] = reconnects
+
+	now := time.Now()
+	meas_name := "instance_state_stats"
+	if len(conf.Prefix) > 0 {
+		meas_name = conf.Prefix + meas_name
+	}
+	m := metric.New(meas_name, tags, fields, now)
+	result = append(result, m)
+	output.SendMetrics(result)
+}
+
+// SendReloadStats sends the outcome of a configuration reload
+func SendReloadStats(success bool, reloadErr error, added int, removed int, changed int, unchanged int, t time.Duration) {
+	result := []telegraf.Metric{}
+
+	tags := make(map[string]string)
+	// and then added Extra tags from sefl-monitor config
+	for k, v := range conf.ExtraLabels {
+		tags[k] = v
+	}
+	fields := make(map[string]interface{})
+	fields["success"] = success
+	fields["error"] = ""
+	if reloadErr != nil {
+		fields["error"] = reloadErr.Error()
+	}
+	fields["groups_added"] = added
+	fields["groups_removed"] = removed
+	fields["groups_changed"] = changed
+	fields["groups_unchanged"] = unchanged
+	fields["duration_us"] = t.Microseconds()
+	now := time.Now()
+	meas_name := "reload_stats"
+	if len(conf.Prefix) > 0 {
+		meas_name = conf.Prefix + meas_name
+	}
+	m := metric.New(meas_name, tags, fields, now)
+	result = append(result, m)
+	output.SendMetrics(result)
+}
+
+func collectOutputStats() (int, error) {
+	result := []telegraf.Metric{}
+	now := time.Now()
+	meas_name := "output_stats"
+	if len(conf.Prefix) > 0 {
+		meas_name = conf.Prefix + meas_name
+	}
+	for _, st := range output.GetStats() {
+		tags := make(map[string]string)
+		// and then added Extra tags from sefl-monitor config
+		for k, v := range conf.ExtraLabels {
+			tags[k] = v
+		}
+		tags["sink"] = st.Name
+		tags["sink_type"] = st.Type
+		fields := make(map[string]interface{})
+		fields["buffer_len"] = st.BufferLen
+		fields["metrics_written"] = st.MetricsWritten
+		fields["metrics_dropped"] = st.MetricsDropped
+		fields["writes"] = st.Writes
+		fields["write_errors"] = st.WriteErrors
+		fields["write_bytes"] = st.Bytes
+		fields["write_retries"] = st.Retries
+		fields["last_http_status"] = st.LastStatus
+		fields["last_write_latency_us"] = st.LastLatency.Microseconds()
+		fields["spool_segments"] = st.SpoolSegments
+		fields["spool_bytes"] = st.SpoolBytes
+		fields["spool_evicted_segments"] = st.SpoolEvicted
+		m := metric.New(meas_name, tags, fields, now)
+		result = append(result, m)
+	}
+	output.SendMetrics(result)
+	return len(result), nil
+}
+
 func collectLegacyRuntimeStats() (int, error) {
 	result := []telegraf.Metric{}
 	nsInMs := float64(time.Millisecond)
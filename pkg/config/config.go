@@ -1,8 +1,12 @@
 package config
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 	"github.com/sirupsen/logrus"
@@ -49,6 +53,28 @@ func LoadConfigFile(filename string) (*Config, error) {
 	if _, err := toml.Decode(string(tomlData), cfg); err != nil {
 		return cfg, err
 	}
+	// ${VAR} expansion and file:/secret: references (SQL statements are kept),
+	// resolved before using the include and discovery files paths
+	cfg.secrets = []string{}
+	err = resolveValues(reflect.ValueOf(cfg), "", "", &cfg.secrets)
+	if err == nil && cfg.OraMon != nil {
+		for _, g := range cfg.OraMon.MetricGroup {
+			g.File = filename
+		}
+		err = loadIncludes(cfg.OraMon, filepath.Dir(filename), &cfg.secrets)
+	}
+	// masked until the config is applied or another one is loaded
+	SetSecrets("config.load", cfg.secrets)
+	if err != nil {
+		return cfg, err
+	}
+	if cfg.Discovery != nil {
+		for i, p := range cfg.Discovery.OracleDiscoveryFiles {
+			if !filepath.IsAbs(p) {
+				cfg.Discovery.OracleDiscoveryFiles[i] = filepath.Join(filepath.Dir(filename), p)
+			}
+		}
+	}
 	// // Validate Some Config
 	// for _, c := range cfg.XXXXX {
 	// 	err := c.ValidateCfg(cfg)
@@ -66,3 +92,65 @@ func LoadConfigFile(filename string) (*Config, error) {
 
 	return cfg, err
 }
+
+// includeFile is the content allowed in included files
+type includeFile struct {
+	MetricGroup []*OracleMetricGroupConfig `toml:"mgroup"`
+}
+
+// loadIncludeFile returns the metric groups defined in the file
+func loadIncludeFile(filename string, found *[]string) ([]*OracleMetricGroupConfig, error) {
+	inc := &includeFile{}
+	md, err := toml.DecodeFile(filename, inc)
+	if err != nil {
+		return nil, fmt.Errorf("Error on included file %s: %s", filename, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("Error on included file %s: only mgroup definitions allowed, found %s", filename, undecoded[0])
+	}
+	if err := resolveValues(reflect.ValueOf(inc), "", "", found); err != nil {
+		return nil, fmt.Errorf("Error on included file %s: %s", filename, err)
+	}
+	for _, g := range inc.MetricGroup {
+		g.File = filename
+	}
+	return inc.MetricGroup, nil
+}
+
+// loadIncludes merges the metric groups from all files matching the include
+// patterns (relative to the main config dir), directories include all its *.toml files
+func loadIncludes(om *OracleMonitorConfig, dir string, found *[]string) error {
+	for _, pattern := range om.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("Error on include pattern %s: %s", pattern, err)
+		}
+		for _, m := range matches {
+			fi, err := os.Stat(m)
+			if err != nil {
+				return fmt.Errorf("Error on included file %s: %s", m, err)
+			}
+			files := []string{m}
+			if fi.IsDir() {
+				files, err = filepath.Glob(filepath.Join(m, "*.toml"))
+				if err != nil {
+					return fmt.Errorf("Error on include dir %s: %s", m, err)
+				}
+			}
+			for _, f := range files {
+				if strings.HasPrefix(filepath.Base(f), ".") {
+					continue
+				}
+				groups, err := loadIncludeFile(f, found)
+				if err != nil {
+					return err
+				}
+				om.MetricGroup = append(om.MetricGroup, groups...)
+			}
+		}
+	}
+	return nil
+}
@@ -3,9 +3,15 @@ package config
 import (
 	"bufio"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
+	"text/template"
 	"time"
+
+	"github.com/hashicorp/go-version"
 )
 
 // GeneralConfig has miscellaneous configuration options
@@ -15,19 +21,65 @@ type GeneralConfig struct {
 	HomeDir    string `toml:"home_dir"`
 	DataDir    string `toml:"data_dir"`
 	LogLevel   string `toml:"log_level"`
+	APIListen  string `toml:"api_listen_address"` // empty: API disabled
 }
 
 func (gc *GeneralConfig) Validate() error {
 	return nil
 }
 
+// PoolConfig has the connection pool settings for each instance
+type PoolConfig struct {
+	PoolMode        string        `toml:"oracle_pool_mode"`          // pool (driver session pool) or standalone
+	MaxOpenConns    int           `toml:"oracle_max_open_conns"`     // also the driver pool max sessions
+	MaxIdleConns    int           `toml:"oracle_max_idle_conns"`     // idle connections kept
+	ConnMaxLifetime time.Duration `toml:"oracle_conn_max_lifetime"`  // 0: no limit
+	ConnMaxIdleTime time.Duration `toml:"oracle_conn_max_idle_time"` // 0: no limit
+}
+
+func (pc *PoolConfig) Validate() error {
+	switch pc.PoolMode {
+	case "", "pool", "standalone":
+	default:
+		return fmt.Errorf("unknown oracle_pool_mode [%s]: Valid modes are [pool,standalone]", pc.PoolMode)
+	}
+	if pc.MaxOpenConns < 0 || pc.MaxIdleConns < 0 || pc.ConnMaxLifetime < 0 || pc.ConnMaxIdleTime < 0 {
+		return fmt.Errorf("connection pool settings should not be negative")
+	}
+	return nil
+}
+
+// Merge returns the settings overridden by the ones set in o
+func (pc PoolConfig) Merge(o PoolConfig) PoolConfig {
+	if len(o.PoolMode) > 0 {
+		pc.PoolMode = o.PoolMode
+	}
+	if o.MaxOpenConns > 0 {
+		pc.MaxOpenConns = o.MaxOpenConns
+	}
+	if o.MaxIdleConns > 0 {
+		pc.MaxIdleConns = o.MaxIdleConns
+	}
+	if o.ConnMaxLifetime > 0 {
+		pc.ConnMaxLifetime = o.ConnMaxLifetime
+	}
+	if o.ConnMaxIdleTime > 0 {
+		pc.ConnMaxIdleTime = o.ConnMaxIdleTime
+	}
+	return pc
+}
+
 type DinamicParams struct {
+	PoolConfig
 	SidRegex          string            `toml:"sid_regex"`
 	R                 *regexp.Regexp    `toml:"-"`
 	ExtraLabels       map[string]string `toml:"extra_labels"`
 	OracleConnectUser string            `toml:"oracle_connect_user"`
-	OracleConnectPass string            `toml:"oracle_connect_pass"`
+	OracleConnectPass string            `toml:"oracle_connect_pass" secret:"true"`
 	OracleConnectDSN  string            `toml:"oracle_connect_dsn"`
+	OracleAuthMode    string            `toml:"oracle_auth_mode"` // password/wallet/os default from [oracle-discovery]
+	AdminRole         string            `toml:"admin_role"`       // SYSDBA/SYSOPER/SYSASM
+	Params            map[string]string `toml:"params"`           // metric params overrides
 }
 
 func (dp *DinamicParams) Validate() error {
@@ -36,38 +88,167 @@ func (dp *DinamicParams) Validate() error {
 		return fmt.Errorf("Error on Dinamic Params: %s: %s", dp.SidRegex, err)
 	}
 	dp.R = r
+	if err := validateAuthMode(dp.OracleAuthMode); err != nil {
+		return fmt.Errorf("Error on Dinamic Params: %s: %s", dp.SidRegex, err)
+	}
+	dp.AdminRole = strings.ToUpper(dp.AdminRole)
+	if err := validateAdminRole(dp.AdminRole); err != nil {
+		return fmt.Errorf("Error on Dinamic Params: %s: %s", dp.SidRegex, err)
+	}
+	if err := dp.PoolConfig.Validate(); err != nil {
+		return fmt.Errorf("Error on Dinamic Params: %s: %s", dp.SidRegex, err)
+	}
+	return nil
+}
+
+// Oracle authentication modes
+const (
+	AuthModePassword = "password" // oracle_connect_user/oracle_connect_pass
+	AuthModeWallet   = "wallet"   // credentials from the wallet (external password store)
+	AuthModeOS       = "os"       // OS authentication
+)
+
+// validateAdminRole checks the administrative privileges supported by the driver
+func validateAdminRole(role string) error {
+	switch role {
+	case "", "SYSDBA", "SYSOPER", "SYSASM":
+		return nil
+	case "SYSDG", "SYSBACKUP", "SYSKM":
+		// godror v0.34 only has the SYSDBA, SYSOPER and SYSASM connection modes
+		return fmt.Errorf("admin_role [%s] is not supported by the Oracle driver (godror v0.34): Valid roles are [SYSDBA,SYSOPER,SYSASM]", role)
+	default:
+		return fmt.Errorf("unknown admin_role [%s]: Valid roles are [SYSDBA,SYSOPER,SYSASM]", role)
+	}
+}
+
+func validateAuthMode(mode string) error {
+	switch mode {
+	case "", AuthModePassword, AuthModeWallet, AuthModeOS:
+		return nil
+	default:
+		return fmt.Errorf("unknown oracle_auth_mode [%s]: Valid modes are [password,wallet,os]", mode)
+	}
+}
+
+// TargetConfig defines a database to be monitored without a local PMON process
+// (also read from JSON/YAML discovery files)
+type TargetConfig struct {
+	Name               string            `toml:"name" json:"name" yaml:"name"`
+	OracleConnectDSN   string            `toml:"oracle_connect_dsn" json:"oracle_connect_dsn" yaml:"oracle_connect_dsn"`
+	OracleConnectUser  string            `toml:"oracle_connect_user" json:"oracle_connect_user" yaml:"oracle_connect_user"` // default from discovery/dynamic-params
+	OracleConnectPass  string            `toml:"oracle_connect_pass" json:"oracle_connect_pass" yaml:"oracle_connect_pass" secret:"true"`
+	ExtraLabels        map[string]string `toml:"extra_labels" json:"extra_labels" yaml:"extra_labels"`
+	ClusterwareEnabled bool              `toml:"oracle_clusterware_enabled" json:"oracle_clusterware_enabled" yaml:"oracle_clusterware_enabled"`
+	OracleAuthMode     string            `toml:"oracle_auth_mode" json:"oracle_auth_mode" yaml:"oracle_auth_mode"` // default from discovery/dynamic-params
+	AdminRole          string            `toml:"admin_role" json:"admin_role" yaml:"admin_role"`                   // default from dynamic-params
+}
+
+func (tc *TargetConfig) Validate() error {
+	if len(tc.Name) == 0 {
+		return fmt.Errorf("Static Target Config parameter: name is mandatory")
+	}
+	if len(tc.OracleConnectDSN) == 0 {
+		return fmt.Errorf("Static Target %s: parameter oracle_connect_dsn is mandatory", tc.Name)
+	}
+	if err := validateAuthMode(tc.OracleAuthMode); err != nil {
+		return fmt.Errorf("Static Target %s: %s", tc.Name, err)
+	}
+	tc.AdminRole = strings.ToUpper(tc.AdminRole)
+	if err := validateAdminRole(tc.AdminRole); err != nil {
+		return fmt.Errorf("Static Target %s: %s", tc.Name, err)
+	}
 	return nil
 }
 
 type DiscoveryConfig struct {
+	PoolConfig
 	OracleClusterwareEnabled       bool              `toml:"oracle_clusterware_enabled"`
 	OracleDiscoveryInterval        time.Duration     `toml:"oracle_discovery_interval"`
 	OracleDiscoverySidRegex        string            `toml:"oracle_discovery_sid_regex"`
 	OracleDiscoverySkipErrorsRegex []string          `toml:"oracle_discovery_skip_errors_regex"`
+	OracleOratabEnabled            bool              `toml:"oracle_oratab_enabled"`
+	OracleOratabFile               string            `toml:"oracle_oratab_file"`
+	OracleDiscoveryFiles           []string          `toml:"oracle_discovery_files"` // glob patterns of JSON/YAML target files
+	OracleDiscoveryHTTPSDURL       string            `toml:"oracle_discovery_http_sd_url"`
+	OracleDiscoveryHTTPSDTimeout   time.Duration     `toml:"oracle_discovery_http_sd_timeout"`
 	SkipErrR                       []*regexp.Regexp  `toml:"-"`
 	OracleConnectUser              string            `toml:"oracle_connect_user"`
-	OracleConnectPass              string            `toml:"oracle_connect_pass"`
+	OracleConnectPass              string            `toml:"oracle_connect_pass" secret:"true"`
 	OracleConnectDSN               string            `toml:"oracle_connect_dsn"`
+	OracleAuthMode                 string            `toml:"oracle_auth_mode"` // password (default), wallet or os
+	OracleTNSAdmin                 string            `toml:"oracle_tns_admin"` // TNS_ADMIN dir (sqlnet.ora with the wallet location)
 	ExtraLabels                    map[string]string `toml:"extra_labels"`
 	OracleStatusExtendedInfo       bool              `toml:"oracle_status_extended_info"`
 	OracleLogLevel                 string            `toml:"oracle_log_level"`
 	DynamicParamsBySID             []*DinamicParams  `toml:"dynamic-params"`
+	StaticTargets                  []*TargetConfig   `toml:"static-target"`
 }
 
 func (dc *DiscoveryConfig) Validate() error {
 	if len(dc.OracleConnectDSN) == 0 {
 		return fmt.Errorf("Discovery Config  parameter: oracle_connect_dsn is mandatory")
 	}
-	if len(dc.OracleConnectUser) == 0 {
-		return fmt.Errorf("Discovery Config  parameter: oracle_connect_user is mandatory")
+	if len(dc.OracleAuthMode) == 0 {
+		dc.OracleAuthMode = AuthModePassword
+	}
+	if err := validateAuthMode(dc.OracleAuthMode); err != nil {
+		return fmt.Errorf("Discovery Config  parameter: %s", err)
+	}
+	// user and password are not needed with wallets or OS authentication
+	if dc.OracleAuthMode == AuthModePassword {
+		if len(dc.OracleConnectUser) == 0 {
+			return fmt.Errorf("Discovery Config  parameter: oracle_connect_user is mandatory")
+		}
+		if len(dc.OracleConnectPass) == 0 {
+			return fmt.Errorf("Discovery Config  parameter: oracle_connect_pass is mandatory")
+		}
+	}
+	if err := dc.PoolConfig.Validate(); err != nil {
+		return fmt.Errorf("Discovery Config  parameter: %s", err)
+	}
+	// default pool settings
+	if len(dc.PoolMode) == 0 {
+		dc.PoolMode = "pool"
+	}
+	if dc.MaxOpenConns == 0 {
+		dc.MaxOpenConns = 10
 	}
-	if len(dc.OracleConnectPass) == 0 {
-		return fmt.Errorf("Discovery Config  parameter: oracle_connect_pass is mandatory")
+	if dc.MaxIdleConns == 0 {
+		dc.MaxIdleConns = dc.MaxOpenConns
 	}
-	_, err := regexp.Compile(dc.OracleDiscoverySidRegex)
+	if len(dc.OracleTNSAdmin) > 0 {
+		if fi, err := os.Stat(dc.OracleTNSAdmin); err != nil || !fi.IsDir() {
+			return fmt.Errorf("Discovery Config  parameter: oracle_tns_admin [%s] should be a directory", dc.OracleTNSAdmin)
+		}
+	}
+	r, err := regexp.Compile(dc.OracleDiscoverySidRegex)
 	if err != nil {
 		return fmt.Errorf("Error on Discovery Config  parameter  oracle_discovery_sid_regex : %s", err)
 	}
+	if r.SubexpIndex("SID") < 0 {
+		return fmt.Errorf("Error on Discovery Config  parameter  oracle_discovery_sid_regex : SID named group is mandatory")
+	}
+	if len(dc.OracleOratabFile) == 0 {
+		dc.OracleOratabFile = "/etc/oratab"
+	}
+	for _, p := range dc.OracleDiscoveryFiles {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return fmt.Errorf("Error on Discovery Config parameter oracle_discovery_files [%s]: %s", p, err)
+		}
+		// dirs are watched for changes: only file names can have wildcards
+		if strings.ContainsAny(filepath.Dir(p), "*?[") {
+			return fmt.Errorf("Error on Discovery Config parameter oracle_discovery_files [%s]: wildcards are only allowed in file names", p)
+		}
+	}
+	if len(dc.OracleDiscoveryHTTPSDURL) > 0 {
+		u, err := url.Parse(dc.OracleDiscoveryHTTPSDURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("Error on Discovery Config parameter oracle_discovery_http_sd_url [%s]: should be a http(s) URL", Redact(dc.OracleDiscoveryHTTPSDURL))
+		}
+	}
+	if dc.OracleDiscoveryHTTPSDTimeout <= 0 {
+		dc.OracleDiscoveryHTTPSDTimeout = 10 * time.Second
+	}
 
 	for _, rexp := range dc.OracleDiscoverySkipErrorsRegex {
 		r, err := regexp.Compile(rexp)
@@ -83,16 +264,255 @@ func (dc *DiscoveryConfig) Validate() error {
 			return err
 		}
 	}
+	names := make(map[string]bool)
+	for _, v := range dc.StaticTargets {
+		err := v.Validate()
+		if err != nil {
+			return err
+		}
+		if names[v.Name] {
+			return fmt.Errorf("Static Target name %s is duplicated", v.Name)
+		}
+		names[v.Name] = true
+	}
 	return nil
 }
 
-type OutputConfig struct {
+// SerializerConfig data format options for the output sinks
+type SerializerConfig struct {
+	DataFormat         string `toml:"data_format"`         // influx/json/graphite/carbon2/prometheus/openmetrics(alias of prometheus)/csv
+	TimestampPrecision string `toml:"timestamp_precision"` // s/ms/us/ns
+	// graphite
+	GraphitePrefix     string `toml:"graphite_prefix"`
+	GraphiteTemplate   string `toml:"graphite_template"`
+	GraphiteTagSupport bool   `toml:"graphite_tag_support"`
+	// carbon2
+	Carbon2Format string `toml:"carbon2_format"`
+	// csv
+	CSVSeparator string `toml:"csv_separator"`
+	CSVHeader    bool   `toml:"csv_header"`
+}
+
+var dataFormats = []string{"influx", "json", "graphite", "carbon2", "prometheus", "openmetrics", "csv"}
+
+var timestampPrecisions = map[string]time.Duration{
+	"s":  time.Second,
+	"ms": time.Millisecond,
+	"us": time.Microsecond,
+	"ns": time.Nanosecond,
+}
+
+// GetTimestampPrecision returns the precision as duration
+func (ser *SerializerConfig) GetTimestampPrecision() time.Duration {
+	if p, ok := timestampPrecisions[ser.TimestampPrecision]; ok {
+		return p
+	}
+	return time.Second
+}
+
+// Validate checks the data format options and sets defaults
+func (ser *SerializerConfig) Validate() error {
+	if len(ser.DataFormat) == 0 {
+		ser.DataFormat = "influx"
+	}
+	valid := false
+	for _, f := range dataFormats {
+		if ser.DataFormat == f {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return fmt.Errorf("unknown data_format [%s]: Valid formats are [%s]", ser.DataFormat, strings.Join(dataFormats, ","))
+	}
+	if len(ser.TimestampPrecision) == 0 {
+		// influx line protocol was always written in ns
+		ser.TimestampPrecision = "s"
+		if ser.DataFormat == "influx" {
+			ser.TimestampPrecision = "ns"
+		}
+	}
+	if _, ok := timestampPrecisions[ser.TimestampPrecision]; !ok {
+		return fmt.Errorf("unknown timestamp_precision [%s]: Valid precisions are [s,ms,us,ns]", ser.TimestampPrecision)
+	}
+	if len(ser.CSVSeparator) > 1 {
+		return fmt.Errorf("csv_separator should be a single character, got [%s]", ser.CSVSeparator)
+	}
+	return nil
+}
+
+// OutputSinkConfig configuration for each output destination
+type OutputSinkConfig struct {
+	Name string `toml:"name"`
+	Type string `toml:"type"` // stdout/file/prometheus/influxdb/influxdb_v2
+	SerializerConfig
 	FlushPeriod time.Duration `toml:"flush_period"`
 	BufferSize  int           `toml:"buffer_size"`
 	BatchSize   int           `toml:"batch_size"`
+	// routing filters (glob patterns)
+	NamePass []string            `toml:"namepass"`
+	NameDrop []string            `toml:"namedrop"`
+	TagPass  map[string][]string `toml:"tagpass"`
+	TagDrop  map[string][]string `toml:"tagdrop"`
+	// file sink
+	File string `toml:"file"`
+	// prometheus sink
+	ListenAddress string `toml:"listen_address"`
+	Path          string `toml:"path"`
+	// influxdb/influxdb_v2 sinks
+	URL              string        `toml:"url"`
+	Database         string        `toml:"database"`
+	RetentionPolicy  string        `toml:"retention_policy"`
+	Username         string        `toml:"username"`
+	Password         string        `toml:"password" secret:"true"`
+	Token            string        `toml:"token" secret:"true"`
+	Organization     string        `toml:"organization"`
+	Bucket           string        `toml:"bucket"`
+	Timeout          time.Duration `toml:"timeout"`
+	MaxRetries       *int          `toml:"max_retries"` // nil: default retries, 0: no retries
+	RetryInterval    time.Duration `toml:"retry_interval"`
+	RetryMaxInterval time.Duration `toml:"retry_max_interval"`
+}
+
+func (sc *OutputSinkConfig) validateInflux() error {
+	if len(sc.URL) == 0 {
+		return fmt.Errorf("Output Sink %s: parameter url is mandatory for %s sinks", sc.Name, sc.Type)
+	}
+	if len(sc.DataFormat) > 0 && sc.DataFormat != "influx" {
+		return fmt.Errorf("Output Sink %s: only influx data_format allowed for %s sinks", sc.Name, sc.Type)
+	}
+	if sc.Type == "influxdb" && len(sc.Database) == 0 {
+		return fmt.Errorf("Output Sink %s: parameter database is mandatory for influxdb sinks", sc.Name)
+	}
+	if sc.Type == "influxdb_v2" && (len(sc.Organization) == 0 || len(sc.Bucket) == 0) {
+		return fmt.Errorf("Output Sink %s: parameters organization and bucket are mandatory for influxdb_v2 sinks", sc.Name)
+	}
+	// set default values
+	if sc.Timeout == 0 {
+		sc.Timeout = 5 * time.Second
+	}
+	if sc.MaxRetries == nil {
+		def := 3
+		sc.MaxRetries = &def
+	}
+	if *sc.MaxRetries < 0 {
+		return fmt.Errorf("Output Sink %s: max_retries should be 0 or greater", sc.Name)
+	}
+	if sc.RetryInterval == 0 {
+		sc.RetryInterval = 1 * time.Second
+	}
+	if sc.RetryMaxInterval == 0 {
+		sc.RetryMaxInterval = 30 * time.Second
+	}
+	return nil
+}
+
+func (sc *OutputSinkConfig) Validate() error {
+	if len(sc.Type) == 0 {
+		sc.Type = "stdout"
+	}
+	switch sc.Type {
+	case "stdout":
+	case "file":
+		if len(sc.File) == 0 {
+			return fmt.Errorf("Output Sink %s: parameter file is mandatory for file sinks", sc.Name)
+		}
+	case "prometheus":
+		if len(sc.ListenAddress) == 0 {
+			return fmt.Errorf("Output Sink %s: parameter listen_address is mandatory for prometheus sinks", sc.Name)
+		}
+		if len(sc.Path) == 0 {
+			sc.Path = "/metrics"
+		}
+		// prometheus sinks are not buffered
+		return nil
+	case "influxdb", "influxdb_v2":
+		if err := sc.validateInflux(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Output Sink %s: unknown type [%s]: Valid types are [stdout,file,prometheus,influxdb,influxdb_v2]", sc.Name, sc.Type)
+	}
+	if sc.FlushPeriod <= 0 {
+		return fmt.Errorf("Output Sink %s: flush_period should be greater than 0", sc.Name)
+	}
+	if sc.BufferSize <= 0 {
+		return fmt.Errorf("Output Sink %s: buffer_size should be greater than 0", sc.Name)
+	}
+	if err := sc.SerializerConfig.Validate(); err != nil {
+		return fmt.Errorf("Output Sink %s: %s", sc.Name, err)
+	}
+	return nil
+}
+
+type OutputConfig struct {
+	SerializerConfig
+	FlushPeriod      time.Duration       `toml:"flush_period"`
+	BufferSize       int                 `toml:"buffer_size"`
+	BatchSize        int                 `toml:"batch_size"`
+	PrometheusListen string              `toml:"prometheus_listen_address"`
+	PrometheusPath   string              `toml:"prometheus_path"`
+	SpoolEnabled     bool                `toml:"spool_enabled"`
+	SpoolMaxSizeMB   int64               `toml:"spool_max_size_mb"`
+	Sinks            []*OutputSinkConfig `toml:"sink"`
+	// configured sinks plus the default/legacy ones (set on Validate)
+	AllSinks []*OutputSinkConfig `toml:"-"`
 }
 
 func (oc *OutputConfig) Validate() error {
+	// sinks with their own data_format will use its default precision
+	precision := oc.TimestampPrecision
+	if err := oc.SerializerConfig.Validate(); err != nil {
+		return fmt.Errorf("Output: %s", err)
+	}
+	if oc.SpoolEnabled && oc.SpoolMaxSizeMB <= 0 {
+		oc.SpoolMaxSizeMB = 100
+	}
+	// Validate can be called more than once: the default sinks are not added to Sinks
+	oc.AllSinks = append([]*OutputSinkConfig{}, oc.Sinks...)
+	// without sinks we will keep the old behaviour: all data to stdout
+	if len(oc.Sinks) == 0 {
+		oc.AllSinks = append(oc.AllSinks, &OutputSinkConfig{Name: "stdout", Type: "stdout"})
+	}
+	if len(oc.PrometheusListen) > 0 {
+		oc.AllSinks = append(oc.AllSinks, &OutputSinkConfig{
+			Name:          "prometheus",
+			Type:          "prometheus",
+			ListenAddress: oc.PrometheusListen,
+			Path:          oc.PrometheusPath,
+		})
+	}
+	names := make(map[string]bool)
+	for i, sc := range oc.AllSinks {
+		if len(sc.Name) == 0 {
+			sc.Name = fmt.Sprintf("%s_%d", sc.Type, i)
+		}
+		if names[sc.Name] {
+			return fmt.Errorf("Output Sink name %s is duplicated", sc.Name)
+		}
+		names[sc.Name] = true
+		// inherit general output settings
+		if sc.FlushPeriod == 0 {
+			sc.FlushPeriod = oc.FlushPeriod
+		}
+		if sc.BufferSize == 0 {
+			sc.BufferSize = oc.BufferSize
+		}
+		if sc.BatchSize == 0 {
+			sc.BatchSize = oc.BatchSize
+		}
+		// influxdb sinks only write line protocol
+		if len(sc.DataFormat) == 0 && sc.Type != "influxdb" && sc.Type != "influxdb_v2" {
+			sc.SerializerConfig = oc.SerializerConfig
+		}
+		if len(sc.TimestampPrecision) == 0 {
+			sc.TimestampPrecision = precision
+		}
+		err := sc.Validate()
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -118,15 +538,59 @@ type OracleMetricConfig struct {
 	MetricsDesc              map[string]string `toml:"metrics_desc"`
 	MetricsType              map[string]string `toml:"metrics_type"`
 	FieldToAppend            string            `toml:"fieldtoappend"`
-	Request                  string            `toml:"request"`
+	Request                  string            `toml:"request" resolve:"false"`
 	IgnoreZeroResult         bool              `toml:"ignorezeroresult"`
+	QueryPeriod              time.Duration     `toml:"query_period"`                  // default group query_period
+	QueryTimeout             time.Duration     `toml:"query_timeout"`                 // default group query_timeout
+	SessionInit              []string          `toml:"session_init" resolve:"false"`  // run after the group session_init
+	SessionReset             []string          `toml:"session_reset" resolve:"false"` // run before the group session_reset
+	Params                   map[string]string `toml:"params"`                        // :name binds and template params
+	// Template is the parsed request if it has {{ }} actions (set on Validate)
+	Template *template.Template `toml:"-"`
 	// MetricsBuckets   map[string]map[string]string
 }
 
+// validateStatements checks the session_init/session_reset statements
+func validateStatements(param string, stmts []string) error {
+	for i, stmt := range stmts {
+		if len(strings.TrimSpace(stmt)) == 0 {
+			return fmt.Errorf("%s[%d] is empty", param, i)
+		}
+	}
+	return nil
+}
+
+// validateSession checks the session statements, session_init needs the
+// session_reset ones to return the connection to the pool
+func validateSession(init []string, reset []string) error {
+	if err := validateStatements("session_init", init); err != nil {
+		return err
+	}
+	if err := validateStatements("session_reset", reset); err != nil {
+		return err
+	}
+	if len(init) > 0 && len(reset) == 0 {
+		return fmt.Errorf("session_reset is mandatory with session_init (the connection would be discarded after each query)")
+	}
+	return nil
+}
+
 func (mc *OracleMetricConfig) Validate() error {
 	if len(mc.Context) == 0 {
 		return fmt.Errorf("Metric Config context  parameter is mandatory")
 	}
+	if err := validateSession(mc.SessionInit, mc.SessionReset); err != nil {
+		return fmt.Errorf("Error in Metric %s : %s", mc.Context, err)
+	}
+	// templates are rendered on each query (see oracle.RequestData)
+	mc.Template = nil
+	if strings.Contains(mc.Request, "{{") {
+		tmpl, err := template.New(mc.Context).Option("missingkey=error").Parse(mc.Request)
+		if err != nil {
+			return fmt.Errorf("Error in Metric %s : request template: %s", mc.Context, err)
+		}
+		mc.Template = tmpl
+	}
 	if len(mc.Request) == 0 {
 		return fmt.Errorf("Metric Config request  parameter is mandatory")
 	}
@@ -136,6 +600,27 @@ func (mc *OracleMetricConfig) Validate() error {
 	if len(mc.ID) == 0 {
 		mc.ID = mc.Context
 	}
+	for _, v := range []string{mc.OraVerGreaterOrEqualThan, mc.OraVerLessThan} {
+		if len(v) == 0 {
+			continue
+		}
+		if _, err := version.NewVersion(v); err != nil {
+			return fmt.Errorf("Error in Metric %s , invalid oracle version [%s]: %s", mc.ID, v, err)
+		}
+	}
+	if len(mc.OraVerGreaterOrEqualThan) > 0 && len(mc.OraVerLessThan) > 0 {
+		goet, _ := version.NewVersion(mc.OraVerGreaterOrEqualThan)
+		lt, _ := version.NewVersion(mc.OraVerLessThan)
+		if !goet.LessThan(lt) {
+			return fmt.Errorf("Error in Metric %s , oracle_version_greater_or_equal_than [%s] should be less than oracle_version_less_than [%s]", mc.ID, mc.OraVerGreaterOrEqualThan, mc.OraVerLessThan)
+		}
+	}
+	if mc.QueryPeriod < 0 || mc.QueryTimeout < 0 {
+		return fmt.Errorf("Error in Metric %s , query_period and query_timeout can not be negative", mc.ID)
+	}
+	if mc.QueryTimeout > mc.QueryPeriod {
+		return fmt.Errorf("Error in Metric %s , query_timeout [%s] should not be greater than query_period [%s]", mc.ID, mc.QueryTimeout, mc.QueryPeriod)
+	}
 
 	for k, v := range mc.MetricsType {
 		switch v {
@@ -148,12 +633,20 @@ func (mc *OracleMetricConfig) Validate() error {
 }
 
 type OracleMetricGroupConfig struct {
-	QueryLevel     string                `toml:"query_level"` // db/instance default  instance
+	QueryLevel     string                `toml:"query_level"` // db/instance/pdb default  instance
 	QueryPeriod    time.Duration         `toml:"query_period"`
 	QueryTimeout   time.Duration         `toml:"query_timeout"`
 	Name           string                `toml:"name"`
 	InstanceFilter string                `toml:"instance_filter"`
+	PdbFilter      string                `toml:"pdb_filter"` // regex on PDB names (only pdb query_level)
+	PdbR           *regexp.Regexp        `toml:"-"`
+	InstanceTypes  []string              `toml:"instance_types"`                // database/asm/apx default database
+	RequiredStatus string                `toml:"required_instance_status"`      // STARTED/MOUNTED/OPEN default OPEN
+	SessionInit    []string              `toml:"session_init" resolve:"false"`  // statements executed before each query
+	SessionReset   []string              `toml:"session_reset" resolve:"false"` // statements restoring the session after each query
+	Params         map[string]string     `toml:"params"`                        // default params for all metrics
 	OracleMetrics  []*OracleMetricConfig `toml:"metric"`
+	File           string                `toml:"-"` // file where the group is defined
 }
 
 func (gc *OracleMetricGroupConfig) Validate() error {
@@ -164,16 +657,89 @@ func (gc *OracleMetricGroupConfig) Validate() error {
 	if len(gc.QueryLevel) == 0 {
 		gc.QueryLevel = "instance"
 	}
+	switch gc.QueryLevel {
+	case "instance", "db", "pdb":
+	default:
+		return fmt.Errorf("Error in MetricGroup %s : unknown query_level [%s]: Valid levels are [instance,db,pdb]", gc.Name, gc.QueryLevel)
+	}
+	if err := validateSession(gc.SessionInit, gc.SessionReset); err != nil {
+		return fmt.Errorf("Error in MetricGroup %s : %s", gc.Name, err)
+	}
+	if len(gc.RequiredStatus) == 0 {
+		gc.RequiredStatus = "OPEN"
+	}
+	gc.RequiredStatus = strings.ToUpper(gc.RequiredStatus)
+	switch gc.RequiredStatus {
+	case "STARTED", "MOUNTED", "OPEN":
+	default:
+		return fmt.Errorf("Error in MetricGroup %s : unknown required_instance_status [%s]: Valid status are [STARTED,MOUNTED,OPEN]", gc.Name, gc.RequiredStatus)
+	}
+	if len(gc.InstanceTypes) == 0 {
+		gc.InstanceTypes = []string{"database"}
+	}
+	for _, t := range gc.InstanceTypes {
+		switch t {
+		case "database", "asm", "apx":
+		default:
+			return fmt.Errorf("Error in MetricGroup %s : unknown instance_types [%s]: Valid types are [database,asm,apx]", gc.Name, t)
+		}
+	}
+	if len(gc.PdbFilter) > 0 {
+		if gc.QueryLevel != "pdb" {
+			return fmt.Errorf("Error in MetricGroup %s : pdb_filter is only allowed with query_level pdb", gc.Name)
+		}
+		r, err := regexp.Compile(gc.PdbFilter)
+		if err != nil {
+			return fmt.Errorf("Error in MetricGroup %s : pdb_filter [%s]: %s", gc.Name, gc.PdbFilter, err)
+		}
+		gc.PdbR = r
+	}
+	if gc.QueryPeriod <= 0 {
+		return fmt.Errorf("Error in MetricGroup %s : query_period (or default_query_period) should be greater than 0", gc.Name)
+	}
+	if gc.QueryTimeout <= 0 {
+		return fmt.Errorf("Error in MetricGroup %s : query_timeout (or default_query_timeout) should be greater than 0", gc.Name)
+	}
+	if gc.QueryTimeout > gc.QueryPeriod {
+		return fmt.Errorf("Error in MetricGroup %s : query_timeout [%s] should not be greater than query_period [%s]", gc.Name, gc.QueryTimeout, gc.QueryPeriod)
+	}
 
 	for _, v := range gc.OracleMetrics {
+		// inherit group scheduling
+		if v.QueryPeriod == 0 {
+			v.QueryPeriod = gc.QueryPeriod
+		}
+		if v.QueryTimeout == 0 {
+			v.QueryTimeout = gc.QueryTimeout
+		}
 		err := v.Validate()
 		if err != nil {
 			return fmt.Errorf("Error in MetricGroup %s : %s", gc.Name, err)
 		}
 	}
+	if tick := gc.TickPeriod(); tick < time.Second {
+		return fmt.Errorf("Error in MetricGroup %s : the greatest common divisor of all query_period values is %s, should be at least 1s", gc.Name, tick)
+	}
 	return nil
 }
 
+func gcd(a, b time.Duration) time.Duration {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// TickPeriod returns the group scheduling period: the greatest common divisor
+// of the group and metric query periods
+func (gc *OracleMetricGroupConfig) TickPeriod() time.Duration {
+	tick := gc.QueryPeriod
+	for _, q := range gc.OracleMetrics {
+		tick = gcd(tick, q.QueryPeriod)
+	}
+	return tick
+}
+
 /*func (omgc *OracleMetricGroupConfig) GetQueryLevel() string {
 	if len(omgc.QueryLevel) > 0 {
 		return omgc.QueryLevel
@@ -184,15 +750,29 @@ func (gc *OracleMetricGroupConfig) Validate() error {
 type OracleMonitorConfig struct {
 	DefaultQueryTimeout time.Duration              `toml:"default_query_timeout"`
 	DefaultQueryPeriod  time.Duration              `toml:"default_query_period"`
+	Include             []string                   `toml:"include"` // glob patterns of files/dirs with mgroup definitions
 	MetricGroup         []*OracleMetricGroupConfig `toml:"mgroup"`
 }
 
 func (om *OracleMonitorConfig) Validate() error {
+	files := make(map[string]string)
 	for _, v := range om.MetricGroup {
+		// inherit default scheduling
+		if v.QueryPeriod == 0 {
+			v.QueryPeriod = om.DefaultQueryPeriod
+		}
+		if v.QueryTimeout == 0 {
+			v.QueryTimeout = om.DefaultQueryTimeout
+		}
 		err := v.Validate()
 		if err != nil {
-			return err
+			return fmt.Errorf("Error in file %s: %s", v.File, err)
+		}
+		// group names identify the running processors on reload
+		if f, ok := files[v.Name]; ok {
+			return fmt.Errorf("Metric Group name %s is duplicated (defined in %s and %s)", v.Name, f, v.File)
 		}
+		files[v.Name] = v.File
 	}
 	return nil
 }
@@ -208,13 +788,15 @@ func (om *OracleMonitorConfig) Resume(f *os.File) {
 			mgc.QueryTimeout)
 		w.WriteString(s)
 		for _, mc := range mgc.OracleMetrics {
-			s := fmt.Sprintf("**\t\t\t METRIC ID: %s | CONTEXT: %s | Version [%s,%s)[%d labels|%d fields]\n",
+			s := fmt.Sprintf("**\t\t\t METRIC ID: %s | CONTEXT: %s | Version [%s,%s)[%d labels|%d fields] [Period:%s|Timeout:%s]\n",
 				mc.ID,
 				mc.Context,
 				mc.OraVerGreaterOrEqualThan,
 				mc.OraVerLessThan,
 				len(mc.Labels),
-				len(mc.MetricsType))
+				len(mc.MetricsType),
+				mc.QueryPeriod,
+				mc.QueryTimeout)
 			w.WriteString(s)
 		}
 	}
@@ -230,6 +812,14 @@ type Config struct {
 	Discovery *DiscoveryConfig     `toml:"oracle-discovery"`
 	OraMon    *OracleMonitorConfig `toml:"oracle-monitor"`
 	// Database DatabaseCfg
+	// secret values found on load
+	secrets []string
+}
+
+// Secrets returns the secret values (passwords, tokens, files/secrets
+// references) found when the config was loaded
+func (c *Config) Secrets() []string {
+	return c.secrets
 }
 
 func (c *Config) Validate() error {
@@ -242,6 +832,9 @@ func (c *Config) Validate() error {
 	if err != nil {
 		return err
 	}
+	if c.Output.SpoolEnabled && len(c.General.DataDir) == 0 {
+		return fmt.Errorf("General Config parameter: data_dir is mandatory if output spool_enabled")
+	}
 
 	err = c.Selfmon.Validate()
 	if err != nil {
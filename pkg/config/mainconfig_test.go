@@ -0,0 +1,255 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOutputConfigValidateIdempotent(t *testing.T) {
+	oc := &OutputConfig{
+		FlushPeriod:      10 * time.Second,
+		BufferSize:       1000,
+		PrometheusListen: ":4000",
+	}
+	for i := 0; i < 2; i++ {
+		if err := oc.Validate(); err != nil {
+			t.Fatalf("validate #%d: unexpected error: %s", i, err)
+		}
+		if len(oc.Sinks) != 0 {
+			t.Errorf("validate #%d: configured sinks modified: %d", i, len(oc.Sinks))
+		}
+		if len(oc.AllSinks) != 2 {
+			t.Fatalf("validate #%d: got %d sinks, want 2", i, len(oc.AllSinks))
+		}
+		if oc.AllSinks[0].Type != "stdout" || oc.AllSinks[1].Type != "prometheus" {
+			t.Errorf("validate #%d: unexpected sinks %s,%s", i, oc.AllSinks[0].Type, oc.AllSinks[1].Type)
+		}
+	}
+}
+
+func TestOutputSinkConfigType(t *testing.T) {
+	tests := []struct {
+		typ  string
+		want string
+		fail bool
+	}{
+		{"", "stdout", false},
+		{"stdout", "stdout", false},
+		{"influxdb", "influxdb", false},
+		{"graphite", "", true},
+	}
+	for _, tt := range tests {
+		sc := &OutputSinkConfig{Name: "test", Type: tt.typ, URL: "http://localhost:8086", Database: "oracle", FlushPeriod: time.Second, BufferSize: 100}
+		err := sc.Validate()
+		if (err != nil) != tt.fail {
+			t.Errorf("type [%s]: got error %v, want error %t", tt.typ, err, tt.fail)
+			continue
+		}
+		if !tt.fail && sc.Type != tt.want {
+			t.Errorf("type [%s]: got %s, want %s", tt.typ, sc.Type, tt.want)
+		}
+	}
+}
+
+func TestSerializerConfigValidate(t *testing.T) {
+	tests := []struct {
+		format    string
+		precision string
+		csvSep    string
+		wantFmt   string
+		wantPrec  string
+		fail      bool
+	}{
+		{"", "", "", "influx", "ns", false},
+		{"influx", "ms", "", "influx", "ms", false},
+		{"json", "", "", "json", "s", false},
+		{"openmetrics", "", "", "openmetrics", "s", false},
+		{"csv", "us", ";", "csv", "us", false},
+		{"csv", "", ";;", "", "", true},
+		{"xml", "", "", "", "", true},
+		{"json", "m", "", "", "", true},
+	}
+	for _, tt := range tests {
+		ser := &SerializerConfig{DataFormat: tt.format, TimestampPrecision: tt.precision, CSVSeparator: tt.csvSep}
+		err := ser.Validate()
+		if (err != nil) != tt.fail {
+			t.Errorf("%s/%s: got error %v, want error %t", tt.format, tt.precision, err, tt.fail)
+			continue
+		}
+		if tt.fail {
+			continue
+		}
+		if ser.DataFormat != tt.wantFmt || ser.TimestampPrecision != tt.wantPrec {
+			t.Errorf("%s/%s: got %s/%s, want %s/%s", tt.format, tt.precision, ser.DataFormat, ser.TimestampPrecision, tt.wantFmt, tt.wantPrec)
+		}
+	}
+}
+
+func TestOutputConfigSinkPrecision(t *testing.T) {
+	oc := &OutputConfig{
+		FlushPeriod: 10 * time.Second,
+		BufferSize:  1000,
+		Sinks: []*OutputSinkConfig{
+			{Name: "default", Type: "stdout"},
+			{Name: "json", Type: "stdout", SerializerConfig: SerializerConfig{DataFormat: "json"}},
+			{Name: "influx", Type: "influxdb", URL: "http://localhost:8086", Database: "oracle"},
+		},
+	}
+	if err := oc.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := map[string]string{"default": "ns", "json": "s", "influx": "ns"}
+	for _, sc := range oc.AllSinks {
+		if sc.TimestampPrecision != want[sc.Name] {
+			t.Errorf("sink %s: got precision %s, want %s", sc.Name, sc.TimestampPrecision, want[sc.Name])
+		}
+	}
+}
+
+func TestGcd(t *testing.T) {
+	tests := []struct {
+		a, b, want time.Duration
+	}{
+		{60 * time.Second, 60 * time.Second, 60 * time.Second},
+		{60 * time.Second, 10 * time.Minute, 60 * time.Second},
+		{60 * time.Second, 90 * time.Second, 30 * time.Second},
+		{7 * time.Second, 5 * time.Second, time.Second},
+		{time.Minute, time.Minute + 500*time.Millisecond, 500 * time.Millisecond},
+		{time.Minute, 0, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := gcd(tt.a, tt.b); got != tt.want {
+			t.Errorf("gcd(%s,%s): got %s, want %s", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMetricGroupTickPeriod(t *testing.T) {
+	tests := []struct {
+		name    string
+		periods []time.Duration
+		tick    time.Duration
+		fail    bool
+	}{
+		{"group period", []time.Duration{0, 0}, time.Minute, false},
+		{"metric overrides", []time.Duration{5 * time.Minute, 90 * time.Second}, 30 * time.Second, false},
+		{"sub-second tick", []time.Duration{time.Minute + 500*time.Millisecond}, 500 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		gc := &OracleMetricGroupConfig{
+			Name:         "test",
+			QueryPeriod:  time.Minute,
+			QueryTimeout: 10 * time.Second,
+		}
+		for i, p := range tt.periods {
+			gc.OracleMetrics = append(gc.OracleMetrics, &OracleMetricConfig{
+				ID:          fmt.Sprintf("m%d", i),
+				Context:     fmt.Sprintf("m%d", i),
+				Request:     "select 1 value from dual",
+				MetricsType: map[string]string{"value": "INTEGER"},
+				QueryPeriod: p,
+			})
+		}
+		err := gc.Validate()
+		if (err != nil) != tt.fail {
+			t.Errorf("%s: got error %v, want error %t", tt.name, err, tt.fail)
+		}
+		if got := gc.TickPeriod(); got != tt.tick {
+			t.Errorf("%s: got tick %s, want %s", tt.name, got, tt.tick)
+		}
+	}
+}
+
+func TestValidateAdminRole(t *testing.T) {
+	tests := []struct {
+		role string
+		fail bool
+	}{
+		{"", false},
+		{"SYSDBA", false},
+		{"SYSOPER", false},
+		{"SYSASM", false},
+		{"SYSDG", true},
+		{"SYSBACKUP", true},
+		{"SYSKM", true},
+		{"DBA", true},
+	}
+	for _, tt := range tests {
+		if err := validateAdminRole(tt.role); (err != nil) != tt.fail {
+			t.Errorf("validateAdminRole(%s): got error %v, want error %t", tt.role, err, tt.fail)
+		}
+	}
+}
+
+func TestPoolConfigMerge(t *testing.T) {
+	base := PoolConfig{PoolMode: "pool", MaxOpenConns: 4, MaxIdleConns: 2, ConnMaxLifetime: time.Hour}
+	tests := []struct {
+		name string
+		o    PoolConfig
+		want PoolConfig
+	}{
+		{"empty", PoolConfig{}, base},
+		{"mode", PoolConfig{PoolMode: "standalone"}, PoolConfig{PoolMode: "standalone", MaxOpenConns: 4, MaxIdleConns: 2, ConnMaxLifetime: time.Hour}},
+		{"conns", PoolConfig{MaxOpenConns: 10, MaxIdleConns: 5}, PoolConfig{PoolMode: "pool", MaxOpenConns: 10, MaxIdleConns: 5, ConnMaxLifetime: time.Hour}},
+		{"times", PoolConfig{ConnMaxLifetime: time.Minute, ConnMaxIdleTime: time.Second}, PoolConfig{PoolMode: "pool", MaxOpenConns: 4, MaxIdleConns: 2, ConnMaxLifetime: time.Minute, ConnMaxIdleTime: time.Second}},
+	}
+	for _, tt := range tests {
+		if got := base.Merge(tt.o); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSession(t *testing.T) {
+	tests := []struct {
+		name  string
+		init  []string
+		reset []string
+		fail  bool
+	}{
+		{"no statements", nil, nil, false},
+		{"init and reset", []string{"ALTER SESSION SET CURRENT_SCHEMA = APP"}, []string{"ALTER SESSION SET CURRENT_SCHEMA = MONIT"}, false},
+		{"only reset", nil, []string{"ALTER SESSION SET CURRENT_SCHEMA = MONIT"}, false},
+		{"init without reset", []string{"ALTER SESSION SET CURRENT_SCHEMA = APP"}, nil, true},
+		{"empty statement", []string{" "}, []string{"ALTER SESSION SET CURRENT_SCHEMA = MONIT"}, true},
+	}
+	for _, tt := range tests {
+		if err := validateSession(tt.init, tt.reset); (err != nil) != tt.fail {
+			t.Errorf("%s: got error %v, want error %t", tt.name, err, tt.fail)
+		}
+	}
+}
+
+// reload compares the validated group configs, a parsed template must not
+// make two identical configs different
+func TestMetricConfigTemplateDeepEqual(t *testing.T) {
+	newMetric := func() *OracleMetricConfig {
+		return &OracleMetricConfig{
+			ID:          "sessions",
+			Context:     "sessions",
+			Request:     "select count(*) value from {{ .ViewPrefix }}SESSION",
+			MetricsType: map[string]string{"value": "INTEGER"},
+		}
+	}
+	a, b := newMetric(), newMetric()
+	for _, mc := range []*OracleMetricConfig{a, b} {
+		if err := mc.Validate(); err != nil {
+			t.Fatalf("validate error: %s", err)
+		}
+	}
+	if a.Template == nil {
+		t.Fatalf("template not parsed on validate")
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("identical validated configs are not equal")
+	}
+	b.Request = "select count(*) value from {{ .ViewPrefix }}PROCESS"
+	if err := b.Validate(); err != nil {
+		t.Fatalf("validate error: %s", err)
+	}
+	if reflect.DeepEqual(a, b) {
+		t.Errorf("different validated configs are equal")
+	}
+}
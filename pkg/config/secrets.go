@@ -0,0 +1,232 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// only ${VAR} form is expanded, SQL requests use $ in view names (v$sysstat)
+var envVarRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// minSecretLen is the minimum length of the registered secrets, shorter
+// values would mask any common text in the logs
+const minSecretLen = 6
+
+var (
+	secretsMutex sync.RWMutex
+	// secrets by source (config, targets file, ...)
+	secrets = make(map[string][]string)
+	// secretsReplacer masks all secrets in one pass (nil without secrets)
+	secretsReplacer *strings.Replacer
+)
+
+// SetSecrets replaces the values registered by the source (nil values
+// unregister all), these values should never be shown in logs
+func SetSecrets(source string, values []string) {
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	if len(values) == 0 {
+		delete(secrets, source)
+	} else {
+		secrets[source] = values
+	}
+	all := make(map[string]bool)
+	for _, vals := range secrets {
+		for _, v := range vals {
+			if len(v) >= minSecretLen {
+				all[v] = true
+			}
+		}
+	}
+	if len(all) == 0 {
+		secretsReplacer = nil
+		return
+	}
+	sorted := make([]string, 0, len(all))
+	for v := range all {
+		sorted = append(sorted, v)
+	}
+	// the first matching secret is replaced: longest ones first
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i]) != len(sorted[j]) {
+			return len(sorted[i]) > len(sorted[j])
+		}
+		return sorted[i] < sorted[j]
+	})
+	pairs := make([]string, 0, 2*len(sorted))
+	for _, v := range sorted {
+		pairs = append(pairs, v, redactMask)
+	}
+	secretsReplacer = strings.NewReplacer(pairs...)
+}
+
+func getSecretsReplacer() *strings.Replacer {
+	secretsMutex.RLock()
+	defer secretsMutex.RUnlock()
+	return secretsReplacer
+}
+
+func readSecretFile(filename string) (string, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// readSecret looks for the secret in the systemd credentials dir or in /run/secrets
+func readSecret(name string) (string, error) {
+	if strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid secret name %s", name)
+	}
+	dirs := []string{}
+	if d := os.Getenv("CREDENTIALS_DIRECTORY"); len(d) > 0 {
+		dirs = append(dirs, d)
+	}
+	dirs = append(dirs, "/run/secrets")
+	for _, d := range dirs {
+		s, err := readSecretFile(filepath.Join(d, name))
+		if err == nil {
+			return s, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("secret %s not found in %s", name, strings.Join(dirs, ","))
+}
+
+// resolveValue expands ${VAR} and resolves file:/path and secret:name references
+func resolveValue(s string) (string, bool, error) {
+	switch {
+	case strings.HasPrefix(s, "file:"):
+		v, err := readSecretFile(strings.TrimPrefix(s, "file:"))
+		return v, true, err
+	case strings.HasPrefix(s, "secret:"):
+		v, err := readSecret(strings.TrimPrefix(s, "secret:"))
+		return v, true, err
+	}
+	var err error
+	ret := envVarRegex.ReplaceAllStringFunc(s, func(m string) string {
+		name := envVarRegex.FindStringSubmatch(m)[1]
+		v, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable %s not defined", name)
+		}
+		return v
+	})
+	return ret, false, err
+}
+
+func tomlName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("toml"), ",")[0]
+	if len(name) == 0 {
+		return f.Name
+	}
+	return name
+}
+
+func joinPath(path string, name string) string {
+	if len(path) == 0 {
+		return name
+	}
+	return path + "." + name
+}
+
+// isResolved returns false for the fields kept as written (SQL statements
+// and request templates, tagged resolve:"false")
+func isResolved(tag reflect.StructTag) bool {
+	return tag.Get("resolve") != "false"
+}
+
+// ResolveValues expands ${VAR} and resolves file:/secret: references in
+// v (a pointer to a string or struct), path is used on error messages.
+// It returns the secret values found.
+func ResolveValues(v interface{}, path string) ([]string, error) {
+	found := []string{}
+	err := resolveValues(reflect.ValueOf(v), path, "", &found)
+	return found, err
+}
+
+// resolveValues walks the config replacing all string values (but SQL
+// requests and statements), values from files/secrets and those in fields
+// tagged as secret are added to found
+func resolveValues(v reflect.Value, path string, tag reflect.StructTag, found *[]string) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return resolveValues(v.Elem(), path, tag, found)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" || f.Tag.Get("toml") == "-" {
+				continue
+			}
+			name := path
+			if !f.Anonymous {
+				name = joinPath(path, tomlName(f))
+			}
+			err := resolveValues(v.Field(i), name, f.Tag, found)
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			err := resolveValues(v.Index(i), fmt.Sprintf("%s[%d]", path, i), tag, found)
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			e := v.MapIndex(k)
+			name := joinPath(path, fmt.Sprint(k.Interface()))
+			if e.Kind() != reflect.String {
+				err := resolveValues(e, name, tag, found)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			// map values are not addressable
+			s, err := resolveString(e.String(), name, tag, found)
+			if err != nil {
+				return err
+			}
+			v.SetMapIndex(k, reflect.ValueOf(s).Convert(e.Type()))
+		}
+	case reflect.String:
+		s, err := resolveString(v.String(), path, tag, found)
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	}
+	return nil
+}
+
+// resolveString returns the resolved value if the field should be resolved
+func resolveString(s string, path string, tag reflect.StructTag, found *[]string) (string, error) {
+	if !isResolved(tag) {
+		return s, nil
+	}
+	ret, isRef, err := resolveValue(s)
+	if err != nil {
+		return s, fmt.Errorf("Error on config value %s: %s", path, err)
+	}
+	if (isRef || tag.Get("secret") == "true") && len(ret) > 0 {
+		*found = append(*found, ret)
+	}
+	return ret, nil
+}
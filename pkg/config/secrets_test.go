@@ -0,0 +1,167 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestResolveValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	passFile := filepath.Join(dir, "pass")
+	if err := ioutil.WriteFile(passFile, []byte("filePassword\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("OC_TEST_USER", "monit")
+	os.Setenv("OC_TEST_HOST", "db1")
+	defer os.Unsetenv("OC_TEST_USER")
+	defer os.Unsetenv("OC_TEST_HOST")
+
+	tests := []struct {
+		name    string
+		in      TargetConfig
+		want    TargetConfig
+		secrets []string
+		fail    bool
+	}{
+		{
+			name:    "env vars in credentials",
+			in:      TargetConfig{Name: "t1", OracleConnectDSN: "${OC_TEST_HOST}:1521/PRO", OracleConnectUser: "${OC_TEST_USER}", OracleConnectPass: "plainPassword"},
+			want:    TargetConfig{Name: "t1", OracleConnectDSN: "db1:1521/PRO", OracleConnectUser: "monit", OracleConnectPass: "plainPassword"},
+			secrets: []string{"plainPassword"},
+		},
+		{
+			name:    "file reference",
+			in:      TargetConfig{Name: "t2", OracleConnectDSN: "db2", OracleConnectPass: "file:" + passFile},
+			want:    TargetConfig{Name: "t2", OracleConnectDSN: "db2", OracleConnectPass: "filePassword"},
+			secrets: []string{"filePassword"},
+		},
+		{
+			name:    "all string fields",
+			in:      TargetConfig{Name: "${OC_TEST_HOST}", OracleConnectDSN: "db3", ExtraLabels: map[string]string{"user": "${OC_TEST_USER}", "f": "file:" + passFile}},
+			want:    TargetConfig{Name: "db1", OracleConnectDSN: "db3", ExtraLabels: map[string]string{"user": "monit", "f": "filePassword"}},
+			secrets: []string{"filePassword"},
+		},
+		{
+			name: "undefined env var",
+			in:   TargetConfig{Name: "t4", OracleConnectDSN: "db4", OracleConnectUser: "${OC_TEST_UNDEFINED}"},
+			fail: true,
+		},
+		{
+			name: "missing file",
+			in:   TargetConfig{Name: "t5", OracleConnectDSN: "db5", OracleConnectPass: "file:" + filepath.Join(dir, "none")},
+			fail: true,
+		},
+	}
+	for _, tt := range tests {
+		got := tt.in
+		secrets, err := ResolveValues(&got, "target")
+		if (err != nil) != tt.fail {
+			t.Errorf("%s: got error %v, want error %t", tt.name, err, tt.fail)
+			continue
+		}
+		if tt.fail {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+		sort.Strings(secrets)
+		if !reflect.DeepEqual(secrets, tt.secrets) {
+			t.Errorf("%s: got secrets %v, want %v", tt.name, secrets, tt.secrets)
+		}
+	}
+}
+
+func TestResolveValuesSQLKept(t *testing.T) {
+	os.Setenv("OC_TEST_VIEW", "should_not_be_used")
+	defer os.Unsetenv("OC_TEST_VIEW")
+	sql := "select * from ${OC_TEST_VIEW} join v$sysstat"
+	stmt := []string{"ALTER SESSION SET CURRENT_SCHEMA = ${OC_TEST_VIEW}"}
+	gc := &OracleMetricGroupConfig{
+		Name:         "${OC_TEST_VIEW}",
+		SessionInit:  stmt,
+		SessionReset: stmt,
+		OracleMetrics: []*OracleMetricConfig{
+			{Context: "${OC_TEST_VIEW}", Request: sql, SessionInit: stmt, SessionReset: stmt, Params: map[string]string{"p": "${OC_TEST_VIEW}"}},
+		},
+	}
+	if _, err := ResolveValues(gc, "group"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	mc := gc.OracleMetrics[0]
+	if mc.Request != sql {
+		t.Errorf("request changed: %s", mc.Request)
+	}
+	for _, s := range [][]string{gc.SessionInit, gc.SessionReset, mc.SessionInit, mc.SessionReset} {
+		if !reflect.DeepEqual(s, stmt) {
+			t.Errorf("session statements changed: %v", s)
+		}
+	}
+	if gc.Name != "should_not_be_used" || mc.Context != "should_not_be_used" || mc.Params["p"] != "should_not_be_used" {
+		t.Errorf("other values not resolved: %s %s %v", gc.Name, mc.Context, mc.Params)
+	}
+}
+
+func TestLoadConfigFileResolve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("OC_TEST_INCLUDE", "groups")
+	os.Setenv("OC_TEST_LABEL", "pro")
+	defer os.Unsetenv("OC_TEST_INCLUDE")
+	defer os.Unsetenv("OC_TEST_LABEL")
+	if err := os.Mkdir(filepath.Join(dir, "groups"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	main := `
+[oracle-discovery]
+oracle_discovery_files = [ "${OC_TEST_INCLUDE}/*.json" ]
+extra_labels = { env = "${OC_TEST_LABEL}" }
+
+[oracle-monitor]
+include = [ "${OC_TEST_INCLUDE}" ]
+`
+	group := `
+[[mgroup]]
+name = "${OC_TEST_LABEL}_sessions"
+  [[mgroup.metric]]
+  context = "sessions"
+  request = "select count(*) value from ${OC_TEST_LABEL}"
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.toml"), []byte(main), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "groups", "g.toml"), []byte(group), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfigFile(filepath.Join(dir, "main.toml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := cfg.Discovery.OracleDiscoveryFiles[0]; got != filepath.Join(dir, "groups", "*.json") {
+		t.Errorf("got discovery file %s", got)
+	}
+	if got := cfg.Discovery.ExtraLabels["env"]; got != "pro" {
+		t.Errorf("got label %s", got)
+	}
+	if len(cfg.OraMon.MetricGroup) != 1 {
+		t.Fatalf("got %d groups, want 1", len(cfg.OraMon.MetricGroup))
+	}
+	g := cfg.OraMon.MetricGroup[0]
+	if g.Name != "pro_sessions" {
+		t.Errorf("got group name %s", g.Name)
+	}
+	if g.OracleMetrics[0].Request != "select count(*) value from ${OC_TEST_LABEL}" {
+		t.Errorf("request changed: %s", g.OracleMetrics[0].Request)
+	}
+}
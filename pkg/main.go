@@ -81,6 +81,8 @@ func init() {
 	customFormatter.TimestampFormat = "2006-01-02 15:04:05"
 	log.Formatter = customFormatter
 	customFormatter.FullTimestamp = true
+	// passwords and secrets should never be written to the log
+	log.AddHook(config.NewRedactHook())
 
 	// parse first time to see if config file is being specified
 	f := flags()
@@ -104,6 +106,7 @@ func init() {
 			log.Errorf("Fatal error in config file: %s \n", err)
 			os.Exit(1)
 		}
+		config.SetSecrets("config", cfg.Secrets())
 		agent.MainConfig = *cfg
 
 		// viper.SetConfigFile(configFile)
@@ -133,7 +136,9 @@ func init() {
 	config.SetLogger(log)
 	config.SetLogDir(logDir)
 	output.SetLogger(log)
+	output.SetDataDir(cfg.General.DataDir)
 	agent.SetLogger(log)
+	agent.SetConfigFile(configFile)
 	oracle.SetLogDir(logDir)
 	oracle.SetLogger(log)
 	selfmon.SetLogger(log)
@@ -151,11 +156,10 @@ func main() {
 	}()
 	writePIDFile()
 	// Init BD config
-	c := make(chan os.Signal)
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 	go func() {
-		select {
-		case sig := <-c:
+		for sig := range c {
 			switch sig {
 			case syscall.SIGTERM:
 				log.Infof("Received TERM signal")
@@ -174,7 +178,10 @@ func main() {
 		}
 	}()
 
-	agent.Start()
+	if err := agent.Start(); err != nil {
+		log.Errorf("Fatal error on start: %s", err)
+		os.Exit(1)
+	}
 
 	// parse input data
 }